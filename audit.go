@@ -0,0 +1,95 @@
+package linken
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditAction ...
+type AuditAction string
+
+const (
+	// AuditActionJoin ...
+	AuditActionJoin AuditAction = "join"
+	// AuditActionLeave ...
+	AuditActionLeave AuditAction = "leave"
+	// AuditActionDisconnect is a node disconnected without leaving, it becomes a zombie
+	AuditActionDisconnect AuditAction = "disconnect"
+	// AuditActionExpire is a zombie node removed after the node expired duration
+	AuditActionExpire AuditAction = "expire"
+	// AuditActionNotify is a node notified its partitions running or stopped
+	AuditActionNotify AuditAction = "notify"
+	// AuditActionTimeout is a partition stuck in Starting or Stopping forced to move, the node is its owner
+	AuditActionTimeout AuditAction = "timeout"
+)
+
+// AuditActorSystem is the actor of the actions not caused by any node, e.g. node expiration
+const AuditActorSystem = "system"
+
+// AuditEntry records a single operation on a group and the changes it caused
+type AuditEntry struct {
+	Time   time.Time   `json:"time"`
+	Group  string      `json:"group"`
+	Actor  string      `json:"actor"`
+	Action AuditAction `json:"action"`
+
+	// the node the action applied to, equals Actor except for system actions
+	Node string `json:"node"`
+
+	BeforeVersion GroupVersion `json:"beforeVersion"`
+	AfterVersion  GroupVersion `json:"afterVersion"`
+	Changes       []GroupEvent `json:"changes,omitempty"`
+}
+
+// AuditSink receives the audit entries, it is called while holding the lock of the group,
+// so it should not block for long
+type AuditSink interface {
+	Record(entry AuditEntry) error
+}
+
+// FileAuditSink is an AuditSink appending each entry as a JSON line to a file
+type FileAuditSink struct {
+	mut  sync.Mutex
+	file *os.File
+}
+
+var _ AuditSink = &FileAuditSink{}
+
+// NewFileAuditSink opens the file for appending, creating it if not existed
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{file: file}, nil
+}
+
+// Record writes the entry with a single write call, so entries are never interleaved
+func (s *FileAuditSink) Record(entry AuditEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	_, err = s.file.Write(content)
+	return err
+}
+
+// Close syncs and closes the file
+func (s *FileAuditSink) Close() error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	err := s.file.Sync()
+	closeErr := s.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,160 @@
+package linken
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type memoryAuditSink struct {
+	entries []AuditEntry
+}
+
+func (s *memoryAuditSink) Record(entry AuditEntry) error {
+	entry.Time = time.Time{}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestLinken_AuditSink(t *testing.T) {
+	sink := &memoryAuditSink{}
+	c := NewFakeClock(time.Now())
+	l := New(WithAuditSink(sink), WithClock(c), WithNodeExpiredDuration(30*time.Second))
+
+	err := l.Join("group01", "node01", 2, nil)
+	assert.Equal(t, nil, err)
+
+	err = l.Join("group01", "node02", 3, nil)
+	assert.Equal(t, ErrInvalidPartitionCount, err)
+
+	l.Notify("group01", "node01", []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 0, LastVersion: 1},
+	})
+	l.Disconnect("group01", "node01")
+
+	c.Advance(30 * time.Second)
+
+	assert.Equal(t, []AuditEntry{
+		{
+			Group: "group01", Actor: "node01", Action: AuditActionJoin, Node: "node01",
+			BeforeVersion: 0, AfterVersion: 1,
+			Changes: []GroupEvent{
+				{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"},
+				{
+					Version: 1, Type: GroupEventTypePartitionChanged,
+					Partition: &PartitionTransition{
+						ID:  0,
+						New: PartitionInfo{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+					},
+				},
+				{
+					Version: 1, Type: GroupEventTypePartitionChanged,
+					Partition: &PartitionTransition{
+						ID:  1,
+						New: PartitionInfo{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+					},
+				},
+			},
+		},
+		{
+			Group: "group01", Actor: "node01", Action: AuditActionNotify, Node: "node01",
+			BeforeVersion: 1, AfterVersion: 2,
+			Changes: []GroupEvent{
+				{
+					Version: 2, Type: GroupEventTypePartitionChanged,
+					Partition: &PartitionTransition{
+						ID:  0,
+						Old: PartitionInfo{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+						New: PartitionInfo{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+					},
+				},
+			},
+		},
+		{
+			Group: "group01", Actor: "node01", Action: AuditActionDisconnect, Node: "node01",
+			BeforeVersion: 2, AfterVersion: 2,
+			Changes: []GroupEvent{
+				{Version: 2, Type: GroupEventTypeNodeZombie, Node: "node01"},
+			},
+		},
+		{
+			Group: "group01", Actor: AuditActorSystem, Action: AuditActionExpire, Node: "node01",
+			BeforeVersion: 2, AfterVersion: 3,
+			Changes: []GroupEvent{
+				{Version: 3, Type: GroupEventTypeNodeLeft, Node: "node01"},
+				{
+					Version: 3, Type: GroupEventTypePartitionChanged,
+					Partition: &PartitionTransition{
+						ID:  0,
+						Old: PartitionInfo{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+						New: PartitionInfo{Status: PartitionStatusInit, ModVersion: 3},
+					},
+				},
+				{
+					Version: 3, Type: GroupEventTypePartitionChanged,
+					Partition: &PartitionTransition{
+						ID:  1,
+						Old: PartitionInfo{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+						New: PartitionInfo{Status: PartitionStatusInit, ModVersion: 3},
+					},
+				},
+			},
+		},
+	}, sink.entries)
+}
+
+func TestLinken_AuditSink_Ignore_Stale_Session(t *testing.T) {
+	sink := &memoryAuditSink{}
+	l := New(WithAuditSink(sink))
+
+	sess1, err := l.JoinSession("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	_, err = l.JoinSession("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	l.LeaveSession("group01", "node01", sess1.ID)
+
+	assert.Equal(t, 2, len(sink.entries))
+	assert.Equal(t, AuditActionJoin, sink.entries[1].Action)
+	assert.Equal(t, []GroupEvent(nil), sink.entries[1].Changes)
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileAuditSink(path)
+	assert.Equal(t, nil, err)
+
+	entries := []AuditEntry{
+		{
+			Time: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), Group: "group01",
+			Actor: "node01", Action: AuditActionJoin, Node: "node01", AfterVersion: 1,
+			Changes: []GroupEvent{{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"}},
+		},
+		{
+			Time: time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC), Group: "group01",
+			Actor: "node01", Action: AuditActionLeave, Node: "node01", BeforeVersion: 1, AfterVersion: 2,
+		},
+	}
+	for _, e := range entries {
+		assert.Equal(t, nil, sink.Record(e))
+	}
+	assert.Equal(t, nil, sink.Close())
+
+	file, err := os.Open(path)
+	assert.Equal(t, nil, err)
+	defer func() { _ = file.Close() }()
+
+	var result []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e AuditEntry
+		assert.Equal(t, nil, json.Unmarshal(scanner.Bytes(), &e))
+		result = append(result, e)
+	}
+	assert.Equal(t, entries, result)
+}
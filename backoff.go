@@ -0,0 +1,98 @@
+package linken
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy computes the durations between reconnect attempts of WebsocketClient
+type BackoffPolicy struct {
+	// Initial is the duration before the first retry, 0 means the duration of WithClientRetryDuration
+	Initial time.Duration
+	// Max is the upper bound of the duration before jitter applied, values < Initial are treated as Initial
+	Max time.Duration
+	// Multiplier is the factor the duration grows after each failed attempt, 0 means 2, other values < 1 are treated as 1
+	Multiplier float64
+	// Jitter randomizes each duration in [d * (1 - Jitter), d * (1 + Jitter)], clamped between 0 and 1.
+	// The randomized duration is never less than Initial / 2
+	Jitter float64
+	// ResetAfter is the duration a connection must stay joined to reset the backoff to Initial, 0 means Max
+	ResetAfter time.Duration
+}
+
+const defaultBackoffMultiplier = 2
+
+// fixedBackoffPolicy always waits d, the behavior when no BackoffPolicy is configured
+func fixedBackoffPolicy(d time.Duration) BackoffPolicy {
+	return BackoffPolicy{
+		Initial:    d,
+		Max:        d,
+		Multiplier: 1,
+	}
+}
+
+// normalize fills the zero fields of a partially configured policy,
+// so the client never retries without waiting
+func (p BackoffPolicy) normalize(retryDuration time.Duration) BackoffPolicy {
+	if p.Initial <= 0 {
+		p.Initial = retryDuration
+	}
+	if p.Max < p.Initial {
+		p.Max = p.Initial
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaultBackoffMultiplier
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.ResetAfter <= 0 {
+		p.ResetAfter = p.Max
+	}
+	return p
+}
+
+type backoff struct {
+	policy  BackoffPolicy
+	rand    *rand.Rand
+	current time.Duration
+}
+
+func newBackoff(policy BackoffPolicy, seed int64) *backoff {
+	return &backoff{
+		policy:  policy,
+		rand:    rand.New(rand.NewSource(seed)),
+		current: policy.Initial,
+	}
+}
+
+// next returns the duration before the next attempt and grows the duration of the following one
+func (b *backoff) next() time.Duration {
+	d := b.current
+
+	b.current = time.Duration(float64(b.current) * b.policy.Multiplier)
+	if b.current > b.policy.Max {
+		b.current = b.policy.Max
+	}
+
+	if b.policy.Jitter > 0 {
+		delta := (b.rand.Float64()*2 - 1) * b.policy.Jitter
+		d = time.Duration(float64(d) * (1 + delta))
+
+		// with a big jitter, the client could retry almost without waiting
+		if d < b.policy.Initial/2 {
+			d = b.policy.Initial / 2
+		}
+	}
+	return d
+}
+
+func (b *backoff) reset() {
+	b.current = b.policy.Initial
+}
@@ -0,0 +1,238 @@
+package linken
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Exponential(t *testing.T) {
+	b := newBackoff(BackoffPolicy{
+		Initial:    100 * time.Millisecond,
+		Max:        time.Second,
+		Multiplier: 2,
+	}, 1)
+
+	assert.Equal(t, 100*time.Millisecond, b.next())
+	assert.Equal(t, 200*time.Millisecond, b.next())
+	assert.Equal(t, 400*time.Millisecond, b.next())
+	assert.Equal(t, 800*time.Millisecond, b.next())
+	assert.Equal(t, time.Second, b.next())
+	assert.Equal(t, time.Second, b.next())
+
+	b.reset()
+	assert.Equal(t, 100*time.Millisecond, b.next())
+}
+
+func TestBackoff_Fixed(t *testing.T) {
+	b := newBackoff(fixedBackoffPolicy(30*time.Second), 1)
+
+	assert.Equal(t, 30*time.Second, b.next())
+	assert.Equal(t, 30*time.Second, b.next())
+}
+
+func TestBackoff_Jitter(t *testing.T) {
+	b := newBackoff(BackoffPolicy{
+		Initial:    time.Second,
+		Max:        time.Second,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}, 1)
+
+	seen := map[time.Duration]struct{}{}
+	for i := 0; i < 100; i++ {
+		d := b.next()
+		assert.GreaterOrEqual(t, d, 800*time.Millisecond)
+		assert.LessOrEqual(t, d, 1200*time.Millisecond)
+		seen[d] = struct{}{}
+	}
+	assert.Greater(t, len(seen), 50)
+}
+
+func TestBackoff_Zero_Max(t *testing.T) {
+	opts := computeClientOptions(WithClientBackoff(BackoffPolicy{
+		Initial:    time.Second,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}))
+	b := newBackoff(opts.backoffPolicy(), 1)
+
+	for i := 0; i < 10; i++ {
+		d := b.next()
+		assert.GreaterOrEqual(t, d, 800*time.Millisecond)
+		assert.LessOrEqual(t, d, 1200*time.Millisecond)
+	}
+}
+
+func TestBackoff_Missing_Multiplier(t *testing.T) {
+	opts := computeClientOptions(WithClientBackoff(BackoffPolicy{
+		Initial: 100 * time.Millisecond,
+		Max:     time.Second,
+	}))
+	b := newBackoff(opts.backoffPolicy(), 1)
+
+	assert.Equal(t, 100*time.Millisecond, b.next())
+	assert.Equal(t, 200*time.Millisecond, b.next())
+	assert.Equal(t, 400*time.Millisecond, b.next())
+}
+
+func TestBackoff_Full_Jitter(t *testing.T) {
+	opts := computeClientOptions(WithClientBackoff(BackoffPolicy{
+		Initial: time.Second,
+		Max:     time.Second,
+		Jitter:  1,
+	}))
+	b := newBackoff(opts.backoffPolicy(), 1)
+
+	for i := 0; i < 1000; i++ {
+		d := b.next()
+		assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+		assert.LessOrEqual(t, d, 2*time.Second)
+	}
+}
+
+func TestBackoffPolicy_Normalize(t *testing.T) {
+	table := []struct {
+		name     string
+		policy   BackoffPolicy
+		expected BackoffPolicy
+	}{
+		{
+			name:   "empty",
+			policy: BackoffPolicy{},
+			expected: BackoffPolicy{
+				Initial:    30 * time.Second,
+				Max:        30 * time.Second,
+				Multiplier: 2,
+				ResetAfter: 30 * time.Second,
+			},
+		},
+		{
+			name: "max-less-than-initial",
+			policy: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        500 * time.Millisecond,
+				Multiplier: 2,
+				ResetAfter: time.Minute,
+			},
+			expected: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        time.Second,
+				Multiplier: 2,
+				ResetAfter: time.Minute,
+			},
+		},
+		{
+			name: "jitter-too-big",
+			policy: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        10 * time.Second,
+				Multiplier: 0.5,
+				Jitter:     1.5,
+			},
+			expected: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        10 * time.Second,
+				Multiplier: 1,
+				Jitter:     1,
+				ResetAfter: 10 * time.Second,
+			},
+		},
+		{
+			name: "negative-jitter",
+			policy: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        10 * time.Second,
+				Multiplier: 2,
+				Jitter:     -0.5,
+			},
+			expected: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        10 * time.Second,
+				Multiplier: 2,
+				ResetAfter: 10 * time.Second,
+			},
+		},
+		{
+			name: "zero-reset-after",
+			policy: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        20 * time.Second,
+				Multiplier: 3,
+			},
+			expected: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        20 * time.Second,
+				Multiplier: 3,
+				ResetAfter: 20 * time.Second,
+			},
+		},
+		{
+			name: "missing-multiplier",
+			policy: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        10 * time.Second,
+				ResetAfter: time.Minute,
+			},
+			expected: BackoffPolicy{
+				Initial:    time.Second,
+				Max:        10 * time.Second,
+				Multiplier: 2,
+				ResetAfter: time.Minute,
+			},
+		},
+	}
+	for _, e := range table {
+		assert.Equal(t, e.expected, e.policy.normalize(30*time.Second), e.name)
+	}
+}
+
+type failedTransport struct {
+	dials chan struct{}
+}
+
+func (t failedTransport) Dial(context.Context) (Conn, error) {
+	t.dials <- struct{}{}
+	return nil, errors.New("dial failed")
+}
+
+func TestWebsocketClient_Reconnect_With_Backoff(t *testing.T) {
+	c := NewFakeClock(time.Now())
+	transport := failedTransport{dials: make(chan struct{}, 10)}
+
+	client := NewClient(transport, "group01", "node01", 3,
+		WithClientClock(c),
+		WithClientBackoff(BackoffPolicy{
+			Initial:    100 * time.Millisecond,
+			Max:        300 * time.Millisecond,
+			Multiplier: 2,
+		}),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Run()
+	}()
+
+	waitForRetry := func() {
+		<-transport.dials
+		for c.PendingTimers() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, d := range []time.Duration{100, 200, 300, 300} {
+		waitForRetry()
+
+		c.Advance(d*time.Millisecond - time.Millisecond)
+		assert.Equal(t, 0, len(transport.dials))
+		c.Advance(time.Millisecond)
+	}
+
+	waitForRetry()
+	client.Shutdown()
+	<-done
+}
@@ -2,78 +2,128 @@ package linken
 
 import (
 	"context"
-	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
-	"net/http"
 	"sync"
 	"time"
 )
 
 // WebsocketClient ...
 type WebsocketClient struct {
-	client  *http.Client
-	url     string
-	options clientOptions
+	clientCore
+
+	transport ClientTransport
 
 	groupName string
-	nodeName  string
-	count     int
 
 	rootCtx context.Context
 	cancel  func()
 
-	prevState *GroupData
+	protocol protocolInfo
+	joined   bool
+	joinedAt time.Time
+
+	safety    safeOwnership
+	lifecycle clientLifecycle
 }
 
 // NewWebsocketClient ...
 func NewWebsocketClient(
 	url string, groupName string, nodeName string, count int,
 	options ...ClientOption,
+) *WebsocketClient {
+	c := NewClient(nil, groupName, nodeName, count, options...)
+	c.transport = NewWebsocketTransport(url, c.options.dialer)
+	return c
+}
+
+// NewClient creates a client connecting to the server using the transport
+func NewClient(
+	transport ClientTransport, groupName string, nodeName string, count int,
+	options ...ClientOption,
 ) *WebsocketClient {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &WebsocketClient{
-		url:     url,
-		options: computeClientOptions(options...),
+		clientCore: newClientCore(nodeName, count, options...),
+
+		transport: transport,
 
 		groupName: groupName,
-		nodeName:  nodeName,
-		count:     count,
 
 		rootCtx: ctx,
 		cancel:  cancel,
 	}
 }
 
-func sleepContext(ctx context.Context, d time.Duration) {
+func sleepContext(ctx context.Context, clock Clock, d time.Duration) {
+	done := make(chan struct{})
+	timer := clock.AfterFunc(d, func() { close(done) })
+	defer timer.Stop()
+
 	select {
 	case <-ctx.Done():
-	case <-time.After(d):
+	case <-done:
 	}
 }
 
-// Run ...
+// Run is RunContext without a context and ignoring the returned error
 func (c *WebsocketClient) Run() {
+	_ = c.RunContext(context.Background())
+}
+
+// RunContext connects to the server and handles the group states, reconnecting when the connection is lost,
+// until ctx is done, Shutdown or Close is called, or the server replied a permanent error.
+// A rejected previous state is not permanent, the client joins again without it.
+// Returns ctx.Err() if ctx is done, the permanent error, or nil if stopped by Shutdown or Close.
+// The client can not be run again after RunContext returned, a concurrent call returns nil immediately
+func (c *WebsocketClient) RunContext(ctx context.Context) error {
+	if !c.lifecycle.begin() {
+		return nil
+	}
+	defer c.lifecycle.end()
+	defer c.safety.stop()
+
+	return runWithContext(ctx, c.rootCtx, c.cancel, c.run)
+}
+
+func (c *WebsocketClient) run() error {
+	clock := c.options.clock
+	policy := c.options.backoffPolicy()
+	retry := newBackoff(policy, clock.Now().UnixNano())
+
+	c.loadPrevState()
 	for {
-		c.runInLoop()
-		sleepContext(c.rootCtx, c.options.retryDuration)
+		err := c.runInLoop()
+		if IsPermanentError(err) && !c.clearRejectedPrevState(err) {
+			return err
+		}
 		if c.rootCtx.Err() != nil {
-			return
+			return nil
+		}
+		if c.joined && clock.Now().Sub(c.joinedAt) >= policy.ResetAfter {
+			retry.reset()
+		}
+		c.setConnectionState(ConnectionStateRetrying)
+		sleepContext(c.rootCtx, clock, retry.next())
+		if c.rootCtx.Err() != nil {
+			return nil
 		}
 	}
 }
 
-func (c *WebsocketClient) runInLoop() {
+func (c *WebsocketClient) runInLoop() error {
 	logger := c.options.logger
+	c.joined = false
 
-	conn, _, err := c.options.dialer.DialContext(c.rootCtx, "ws://localhost:8765/core", nil)
+	conn, err := c.transport.Dial(c.rootCtx)
 	if err != nil {
 		logger.Error("Dial server failed", zap.Error(err))
-		return
+		return err
 	}
 	defer func() {
 		_ = conn.Close()
 	}()
+	defer c.status.disconnected()
 
 	err = conn.WriteJSON(ServerCommand{
 		Type: ServerCommandTypeJoin,
@@ -83,18 +133,26 @@ func (c *WebsocketClient) runInLoop() {
 			PartitionCount: c.count,
 			Secret:         c.options.secret,
 			PrevState:      c.prevState,
+
+			ProtocolVersions: c.options.protocolVersions,
+			Capabilities:     c.options.capabilities,
 		},
 	})
 	if err != nil {
 		logger.Error("Error while WriteJSON", zap.Error(err))
-		return
+		return err
 	}
 
+	c.setConnectionState(ConnectionStateConnected)
+	defer c.setConnectionState(ConnectionStateDisconnected)
+
 	notifyCh := make(chan []NotifyPartitionData, 1)
 	ctx, cancel := context.WithCancel(c.rootCtx)
 
 	c.prevState = nil
+	c.protocol = protocolInfo{version: ProtocolVersion1}
 
+	var serverErr error
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -110,7 +168,10 @@ func (c *WebsocketClient) runInLoop() {
 		defer cancel()
 
 		for {
-			continuing := c.runSingleHandlingLoop(ctx, conn, notifyCh)
+			continuing, err := c.runSingleHandlingLoop(ctx, conn, notifyCh)
+			if err != nil {
+				serverErr = err
+			}
 			if c.rootCtx.Err() != nil {
 				return
 			}
@@ -121,45 +182,10 @@ func (c *WebsocketClient) runInLoop() {
 	}()
 
 	wg.Wait()
+	return serverErr
 }
 
-func (c *WebsocketClient) runNodeListener(data GroupData) {
-	var prevNodes []string
-	if c.prevState != nil {
-		prevNodes = c.prevState.Nodes
-	}
-
-	if nodesChanged(prevNodes, data.Nodes) {
-		c.options.nodeListener(data.Nodes)
-	}
-}
-
-func (c *WebsocketClient) runPartitionListener(data GroupData) {
-	for i, p := range data.Partitions {
-		id := PartitionID(i)
-
-		prev := PartitionInfo{}
-		if c.prevState != nil {
-			prev = c.prevState.Partitions[i]
-		}
-
-		prevOwner := ""
-		if prev.Status == PartitionStatusRunning {
-			prevOwner = c.prevState.Partitions[i].Owner
-		}
-
-		owner := ""
-		if p.Status == PartitionStatusRunning {
-			owner = p.Owner
-		}
-
-		if prevOwner != owner {
-			c.options.partitionListener(id, owner)
-		}
-	}
-}
-
-func (c *WebsocketClient) notifyServer(ctx context.Context, conn *websocket.Conn, ch <-chan []NotifyPartitionData) {
+func (c *WebsocketClient) notifyServer(ctx context.Context, conn Conn, ch <-chan []NotifyPartitionData) {
 	logger := c.options.logger
 	defer closeConnGracefully(c.rootCtx, conn, logger)
 
@@ -169,115 +195,79 @@ func (c *WebsocketClient) notifyServer(ctx context.Context, conn *websocket.Conn
 			return
 
 		case notifyList := <-ch:
-			err := conn.WriteJSON(ServerCommand{
+			cmd := ServerCommand{
 				Type:   ServerCommandTypeNotify,
 				Notify: notifyList,
-			})
+			}
+			if c.protocol.hasCapability(CapabilityMultiGroup) {
+				// the server treats the connection as a multi group connection, requiring the group of commands
+				cmd.Group = c.groupName
+			}
+
+			err := conn.WriteJSON(cmd)
 			if err != nil {
 				logger.Error("Error while WriteJSON", zap.Error(err))
 				return
 			}
+			c.status.removePending(len(notifyList))
 		}
 	}
 }
 
 func (c *WebsocketClient) runSingleHandlingLoop(
-	ctx context.Context, conn *websocket.Conn, notifyCh chan<- []NotifyPartitionData,
-) bool {
+	ctx context.Context, conn Conn, notifyCh chan<- []NotifyPartitionData,
+) (bool, error) {
 	logger := c.options.logger
 
-	var data GroupData
-	err := conn.ReadJSON(&data)
+	var resp ServerResponse
+	err := conn.ReadJSON(&resp)
 	if err != nil {
 		if errorIsCloseNormal(err) {
-			return false
+			return false, nil
 		}
 		logger.Error("Error while ReadJSON", zap.Error(err))
-		return false
+		return false, nil
 	}
 
-	c.runNodeListener(data)
-	c.runPartitionListener(data)
-
-	var prevPartitions []PartitionInfo
-	if c.prevState != nil {
-		prevPartitions = c.prevState.Partitions
+	if resp.Error != nil {
+		logger.Error("Server replied error", zap.Error(resp.Error))
+		c.options.errorListener(resp.Error)
+		return false, resp.Error
+	}
+	if resp.ProtocolVersion != 0 {
+		c.protocol = protocolInfo{
+			version:      resp.ProtocolVersion,
+			capabilities: resp.Capabilities,
+		}
+	}
+	if !c.joined {
+		c.status.setProtocol(c.protocol)
+		c.joined = true
+		c.joinedAt = c.options.clock.Now()
+		c.setConnectionState(ConnectionStateJoined)
 	}
 
-	notifyList := computeClientNotifyList(c.nodeName, prevPartitions, data.Partitions)
+	notifyList := c.handleGroupData(resp.GroupData)
 	if len(notifyList) > 0 {
+		c.status.addPending(notifyList)
 		select {
 		case <-ctx.Done():
 		case notifyCh <- notifyList:
 		}
 	}
-
-	c.prevState = &data
-	return true
+	return true, nil
 }
 
-// Shutdown ...
+// Shutdown stops the client without waiting, use Close to wait for it to stop
 func (c *WebsocketClient) Shutdown() {
 	c.cancel()
 }
 
-func nodesChanged(prevNodes []string, current []string) bool {
-	prev := map[string]bool{}
-	for _, n := range prevNodes {
-		prev[n] = false
-	}
-
-	for _, n := range current {
-		_, existed := prev[n]
-		if !existed {
-			return true
-		}
-		prev[n] = true
-	}
-
-	for _, visited := range prev {
-		if !visited {
-			return true
-		}
-	}
-
-	return false
-}
-
-func computeClientNotifyList(
-	nodeName string, prevPartitions []PartitionInfo, current []PartitionInfo,
-) []NotifyPartitionData {
-	var notifyList []NotifyPartitionData
-	for id, p := range current {
-		prev := PartitionInfo{}
-		if len(prevPartitions) > 0 {
-			prev = prevPartitions[id]
-		}
-
-		if p.ModVersion <= prev.ModVersion {
-			continue
-		}
-
-		if p.Owner != nodeName {
-			continue
-		}
-
-		if p.Status == PartitionStatusStarting {
-			notifyList = append(notifyList, NotifyPartitionData{
-				Action:      NotifyActionTypeRunning,
-				Partition:   PartitionID(id),
-				LastVersion: p.ModVersion,
-			})
-			continue
-		}
-
-		if p.Status == PartitionStatusStopping {
-			notifyList = append(notifyList, NotifyPartitionData{
-				Action:      NotifyActionTypeStopped,
-				Partition:   PartitionID(id),
-				LastVersion: p.ModVersion,
-			})
-		}
-	}
-	return notifyList
+// Close stops the client and waits for RunContext to return,
+// no listener is called after Close returned
+func (c *WebsocketClient) Close() error {
+	c.cancel()
+	c.lifecycle.close()
+	c.safety.stop()
+	return nil
 }
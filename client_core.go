@@ -0,0 +1,220 @@
+package linken
+
+import (
+	"errors"
+	"go.uber.org/zap"
+)
+
+// clientCore handles the group states received by a node, shared by WebsocketClient and LocalClient
+type clientCore struct {
+	options clientOptions
+
+	nodeName string
+	count    int
+
+	prevState *GroupData
+
+	status *clientStatus
+}
+
+func newClientCore(nodeName string, count int, options ...ClientOption) clientCore {
+	return clientCore{
+		options: computeClientOptions(options...),
+
+		nodeName: nodeName,
+		count:    count,
+
+		status: &clientStatus{},
+	}
+}
+
+func (c *clientCore) loadPrevState() {
+	if c.options.stateStore == nil {
+		return
+	}
+
+	data, err := c.options.stateStore.Load()
+	if err != nil {
+		c.options.logger.Error("Load previous state failed", zap.Error(err))
+		return
+	}
+	if data == nil {
+		return
+	}
+	if len(data.Partitions) != c.count {
+		c.options.logger.Warn("Ignore previous state with different partition count")
+		return
+	}
+	err = Validate(*data)
+	if err != nil {
+		c.options.logger.Warn("Ignore invalid previous state", zap.Error(err))
+		return
+	}
+	c.prevState = data
+}
+
+// clearRejectedPrevState returns true if err is the server rejected the previous state,
+// the state is then cleared from the client and the store, so the next join starts without it
+func (c *clientCore) clearRejectedPrevState(err error) bool {
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) || serverErr.Code != ServerErrorCodeInvalidPrevState {
+		return false
+	}
+
+	c.prevState = nil
+	if c.options.stateStore == nil {
+		return true
+	}
+
+	err = c.options.stateStore.Clear()
+	if err != nil {
+		c.options.logger.Error("Clear previous state failed", zap.Error(err))
+	}
+	return true
+}
+
+func (c *clientCore) saveState(data GroupData) {
+	if c.options.stateStore == nil {
+		return
+	}
+
+	err := c.options.stateStore.Save(data)
+	if err != nil {
+		c.options.logger.Error("Save state failed", zap.Error(err))
+	}
+}
+
+func (c *clientCore) runNodeListener(data GroupData) {
+	var prevNodes []string
+	if c.prevState != nil {
+		prevNodes = c.prevState.Nodes
+	}
+
+	if nodesChanged(prevNodes, data.Nodes) {
+		c.options.nodeListener(data.Nodes)
+	}
+}
+
+func (c *clientCore) runPartitionListener(data GroupData) {
+	for i, p := range data.Partitions {
+		id := PartitionID(i)
+
+		prev := PartitionInfo{}
+		if c.prevState != nil {
+			prev = c.prevState.Partitions[i]
+		}
+
+		prevOwner := ""
+		if prev.Status == PartitionStatusRunning {
+			prevOwner = c.prevState.Partitions[i].Owner
+		}
+
+		owner := ""
+		if p.Status == PartitionStatusRunning {
+			owner = p.Owner
+		}
+
+		if prevOwner != owner {
+			c.options.partitionListener(id, owner)
+		}
+	}
+}
+
+func (c *clientCore) runReplicaListener(data GroupData) {
+	for i := range data.Partitions {
+		id := PartitionID(i)
+
+		var prevReplicas []string
+		if c.prevState != nil {
+			prevReplicas = replicasOf(*c.prevState, id)
+		}
+
+		replicas := replicasOf(data, id)
+		if !replicasEqual(prevReplicas, replicas) {
+			c.options.replicaListener(id, replicas)
+		}
+	}
+}
+
+// handleGroupData calls the listeners and returns the list of partitions need to be notified to the server
+func (c *clientCore) handleGroupData(data GroupData) []NotifyPartitionData {
+	c.runNodeListener(data)
+	c.runPartitionListener(data)
+	c.runReplicaListener(data)
+
+	var prevPartitions []PartitionInfo
+	if c.prevState != nil {
+		prevPartitions = c.prevState.Partitions
+	}
+
+	notifyList := computeClientNotifyList(c.nodeName, prevPartitions, data.Partitions)
+
+	c.prevState = &data
+	c.saveState(data)
+	c.status.setData(data)
+	if c.options.router != nil {
+		c.options.router.Update(data)
+	}
+	return notifyList
+}
+
+func nodesChanged(prevNodes []string, current []string) bool {
+	prev := map[string]bool{}
+	for _, n := range prevNodes {
+		prev[n] = false
+	}
+
+	for _, n := range current {
+		_, existed := prev[n]
+		if !existed {
+			return true
+		}
+		prev[n] = true
+	}
+
+	for _, visited := range prev {
+		if !visited {
+			return true
+		}
+	}
+
+	return false
+}
+
+func computeClientNotifyList(
+	nodeName string, prevPartitions []PartitionInfo, current []PartitionInfo,
+) []NotifyPartitionData {
+	var notifyList []NotifyPartitionData
+	for id, p := range current {
+		prev := PartitionInfo{}
+		if len(prevPartitions) > 0 {
+			prev = prevPartitions[id]
+		}
+
+		if p.ModVersion <= prev.ModVersion {
+			continue
+		}
+
+		if p.Owner != nodeName {
+			continue
+		}
+
+		if p.Status == PartitionStatusStarting {
+			notifyList = append(notifyList, NotifyPartitionData{
+				Action:      NotifyActionTypeRunning,
+				Partition:   PartitionID(id),
+				LastVersion: p.ModVersion,
+			})
+			continue
+		}
+
+		if p.Status == PartitionStatusStopping {
+			notifyList = append(notifyList, NotifyPartitionData{
+				Action:      NotifyActionTypeStopped,
+				Partition:   PartitionID(id),
+				LastVersion: p.ModVersion,
+			})
+		}
+	}
+	return notifyList
+}
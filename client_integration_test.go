@@ -1,8 +1,10 @@
 package linken
 
 import (
+	"context"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -229,3 +231,375 @@ func TestWebsocketClient_With_Secret(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestWebsocketClient_Stop_Retrying_On_Permanent_Error(t *testing.T) {
+	tc := newTestCase(WithGroupSecret("group01",
+		GroupSecret{Write: "write-secret", Read: "read-secret"}))
+	defer tc.shutdown()
+
+	var replied []error
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 3,
+		WithClientGroupSecret("wrong-secret"),
+		WithClientRetryDuration(10*time.Millisecond),
+		WithClientErrorListener(func(err error) {
+			replied = append(replied, err)
+		}),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Run()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("client must stop retrying")
+	}
+
+	assert.Equal(t, []error{
+		&ServerError{
+			Code:    ServerErrorCodeUnauthorized,
+			Message: "invalid 'secret' for write permission",
+		},
+	}, replied)
+}
+
+func TestWebsocketClient_Negotiated_Multi_Group_Capability(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 2,
+		WithClientCapabilities(CapabilityMultiGroup),
+		WithClientLogger(tc.logger),
+	)
+	anotherClient := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node02", 2,
+		WithClientLogger(tc.logger),
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	snapshot := client.Snapshot()
+	assert.Equal(t, ProtocolVersion1, snapshot.ProtocolVersion)
+	assert.Equal(t, []Capability{CapabilityMultiGroup}, snapshot.Capabilities)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		anotherClient.Run()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// the notify commands are tagged with the group, the partition is moved to node02
+	assert.Equal(t, 1, client.Snapshot().RunningCount())
+	assert.Equal(t, 1, anotherClient.Snapshot().RunningCount())
+
+	snapshot = anotherClient.Snapshot()
+	assert.Equal(t, ProtocolVersion1, snapshot.ProtocolVersion)
+	assert.Equal(t, []Capability(nil), snapshot.Capabilities)
+
+	client.Shutdown()
+	anotherClient.Shutdown()
+	wg.Wait()
+}
+
+func TestWebsocketClient_Retry_Without_Rejected_Prev_State(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	err := NewFileStateStore(path).Save(GroupData{
+		Version: 0,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01"},
+		},
+	})
+	assert.Equal(t, nil, err)
+
+	var mut sync.Mutex
+	var replied []error
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 1,
+		WithClientStateFile(path),
+		WithClientRetryDuration(10*time.Millisecond),
+		WithClientErrorListener(func(err error) {
+			mut.Lock()
+			replied = append(replied, err)
+			mut.Unlock()
+		}),
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []error{
+		&ServerError{
+			Code:    ServerErrorCodeInvalidPrevState,
+			Message: "previous state 'version' field must >= 1",
+		},
+	}, replied)
+	mut.Unlock()
+
+	snapshot := client.Snapshot()
+	assert.Equal(t, true, snapshot.Connected)
+	assert.Equal(t, 1, snapshot.RunningCount())
+
+	client.Shutdown()
+	wg.Wait()
+
+	data, err := NewFileStateStore(path).Load()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GroupVersion(2), data.Version)
+}
+
+func TestWebsocketClient_With_State_Store(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	err := NewFileStateStore(path).Save(GroupData{
+		Version: 20,
+		Nodes:   []string{"node01", "node02"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 18},
+			{Status: PartitionStatusRunning, Owner: "node02", ModVersion: 19},
+		},
+	})
+	assert.Equal(t, nil, err)
+
+	var mut sync.Mutex
+	var listenedNodes []string
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 2,
+		WithClientStateFile(path),
+		WithClientNodeListener(func(nodes []string) {
+			mut.Lock()
+			listenedNodes = nodes
+			mut.Unlock()
+		}),
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	mut.Lock()
+	assert.Equal(t, []string{"node01", "node02"}, listenedNodes)
+	mut.Unlock()
+
+	client.Shutdown()
+	wg.Wait()
+
+	data, err := NewFileStateStore(path).Load()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GroupVersion(21), data.Version)
+	assert.Equal(t, []string{"node01", "node02"}, data.Nodes)
+}
+
+func TestWebsocketClient_Connection_Listener_And_Safe_Ownership(t *testing.T) {
+	tc := newTestCase()
+
+	var mut sync.Mutex
+	var states []ConnectionState
+	var updated []partitionUpdated
+
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 2,
+		WithClientPartitionListener(func(p PartitionID, owner string) {
+			mut.Lock()
+			updated = append(updated, partitionUpdated{id: p, owner: owner})
+			mut.Unlock()
+		}),
+		WithClientConnectionListener(func(state ConnectionState) {
+			mut.Lock()
+			states = append(states, state)
+			mut.Unlock()
+		}),
+		WithClientLogger(tc.logger),
+		WithClientRetryDuration(300*time.Millisecond),
+		WithClientSafeOwnership(100*time.Millisecond),
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	// Server Restart
+	tc.shutdown()
+	time.Sleep(150 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []ConnectionState{
+		ConnectionStateConnected,
+		ConnectionStateJoined,
+		ConnectionStateDisconnected,
+		ConnectionStateRetrying,
+	}, states)
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+		{id: 0, owner: ""},
+		{id: 1, owner: ""},
+	}, updated)
+	mut.Unlock()
+
+	tc = newTestCase()
+	defer tc.shutdown()
+
+	time.Sleep(250 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []ConnectionState{
+		ConnectionStateConnected,
+		ConnectionStateJoined,
+		ConnectionStateDisconnected,
+		ConnectionStateRetrying,
+		ConnectionStateConnected,
+		ConnectionStateJoined,
+	}, states)
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+		{id: 0, owner: ""},
+		{id: 1, owner: ""},
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+	}, updated)
+	mut.Unlock()
+
+	client.Shutdown()
+	wg.Wait()
+}
+
+func TestWebsocketClient_Safe_Ownership_Not_Revoked_After_Shutdown(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	var mut sync.Mutex
+	var updated []partitionUpdated
+
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 2,
+		WithClientPartitionListener(func(p PartitionID, owner string) {
+			mut.Lock()
+			updated = append(updated, partitionUpdated{id: p, owner: owner})
+			mut.Unlock()
+		}),
+		WithClientLogger(tc.logger),
+		WithClientSafeOwnership(50*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.RunContext(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-errCh)
+
+	time.Sleep(100 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+	}, updated)
+	mut.Unlock()
+}
+
+func TestWebsocketClient_RunContext_Returns_Permanent_Error(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	err := tc.handler.linken.Join("group01", "node02", 2, nil)
+	assert.Equal(t, nil, err)
+
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 3,
+		WithClientRetryDuration(10*time.Millisecond),
+	)
+
+	err = client.RunContext(context.Background())
+	assert.Equal(t, &ServerError{
+		Code:    ServerErrorCodeInvalidPartitionCount,
+		Message: ErrInvalidPartitionCount.Error(),
+	}, err)
+	assert.Equal(t, nil, client.Close())
+}
+
+func TestWebsocketClient_RunContext_Cancel_And_Close(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	var mut sync.Mutex
+	var states []ConnectionState
+	client := NewWebsocketClient(
+		"ws://localhost:8765/core",
+		"group01", "node01", 3,
+		WithClientConnectionListener(func(state ConnectionState) {
+			mut.Lock()
+			states = append(states, state)
+			mut.Unlock()
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.RunContext(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.Equal(t, nil, client.Close())
+
+	mut.Lock()
+	assert.Equal(t, []ConnectionState{
+		ConnectionStateConnected,
+		ConnectionStateJoined,
+		ConnectionStateDisconnected,
+	}, states)
+	mut.Unlock()
+
+	assert.Equal(t, context.Canceled, <-errCh)
+	assert.Equal(t, nil, client.RunContext(context.Background()))
+}
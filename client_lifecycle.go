@@ -0,0 +1,65 @@
+package linken
+
+import (
+	"context"
+	"sync"
+)
+
+// clientLifecycle lets Close wait for the running RunContext to return
+type clientLifecycle struct {
+	mut     sync.Mutex
+	closed  bool
+	running bool
+	done    chan struct{}
+}
+
+// begin returns false if the client is already closed or another RunContext is running
+func (l *clientLifecycle) begin() bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.closed || l.running {
+		return false
+	}
+	l.running = true
+	l.done = make(chan struct{})
+	return true
+}
+
+func (l *clientLifecycle) end() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.running = false
+	close(l.done)
+}
+
+// close waits for the running RunContext, if any
+func (l *clientLifecycle) close() {
+	l.mut.Lock()
+	l.closed = true
+	done := l.done
+	l.mut.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// runWithContext calls cancel when ctx is done, and returns ctx.Err() in that case instead of the result of run
+func runWithContext(ctx context.Context, rootCtx context.Context, cancel func(), run func() error) error {
+	defer cancel()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-rootCtx.Done():
+		}
+	}()
+
+	err := run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package linken
 
 import (
+	"crypto/tls"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 	"time"
@@ -12,13 +13,33 @@ type ClientNodeListener func(nodes []string)
 // ClientPartitionListener ...
 type ClientPartitionListener func(partition PartitionID, owner string)
 
+// ClientReplicaListener is called when the replicas of a partition changed, see WithReplicaCount
+type ClientReplicaListener func(partition PartitionID, replicas []string)
+
+// ClientErrorListener is called when the server replies an error before closing the connection
+type ClientErrorListener func(err error)
+
+// ClientConnectionListener is called on every change of the connection state
+type ClientConnectionListener func(state ConnectionState)
+
 type clientOptions struct {
 	dialer            *websocket.Dialer
 	nodeListener      ClientNodeListener
 	partitionListener ClientPartitionListener
+	replicaListener   ClientReplicaListener
+	errorListener     ClientErrorListener
+	connListener      ClientConnectionListener
 	logger            *zap.Logger
 	retryDuration     time.Duration
 	secret            string
+	tlsConfig         *tls.Config
+	protocolVersions  []ProtocolVersion
+	capabilities      []Capability
+	stateStore        ClientStateStore
+	clock             Clock
+	safeOwnership     time.Duration
+	backoff           *BackoffPolicy
+	router            *Router
 }
 
 // ClientOption ...
@@ -29,12 +50,23 @@ func computeClientOptions(options ...ClientOption) clientOptions {
 		dialer:            websocket.DefaultDialer,
 		nodeListener:      func(nodes []string) {},
 		partitionListener: func(partition PartitionID, owner string) {},
+		replicaListener:   func(partition PartitionID, replicas []string) {},
+		errorListener:     func(err error) {},
+		connListener:      func(state ConnectionState) {},
 		logger:            zap.NewNop(),
 		retryDuration:     30 * time.Second,
+		protocolVersions:  defaultProtocolVersions,
+		capabilities:      defaultCapabilities,
+		clock:             SystemClock{},
 	}
 	for _, o := range options {
 		o(&opts)
 	}
+	if opts.tlsConfig != nil {
+		dialer := *opts.dialer
+		dialer.TLSClientConfig = opts.tlsConfig
+		opts.dialer = &dialer
+	}
 	return opts
 }
 
@@ -52,6 +84,21 @@ func WithClientPartitionListener(listener ClientPartitionListener) ClientOption
 	}
 }
 
+// WithClientReplicaListener sets the listener called when the replicas of a partition changed,
+// for the standby nodes to warm up the partitions they may take over
+func WithClientReplicaListener(listener ClientReplicaListener) ClientOption {
+	return func(opts *clientOptions) {
+		opts.replicaListener = listener
+	}
+}
+
+// WithClientErrorListener ...
+func WithClientErrorListener(listener ClientErrorListener) ClientOption {
+	return func(opts *clientOptions) {
+		opts.errorListener = listener
+	}
+}
+
 // WithClientDialer ...
 func WithClientDialer(dialer *websocket.Dialer) ClientOption {
 	return func(opts *clientOptions) {
@@ -79,3 +126,83 @@ func WithClientGroupSecret(secret string) ClientOption {
 		opts.secret = secret
 	}
 }
+
+// WithClientTLSConfig sets the TLS config (including client certificates) used when dialing the server
+func WithClientTLSConfig(config *tls.Config) ClientOption {
+	return func(opts *clientOptions) {
+		opts.tlsConfig = config
+	}
+}
+
+// WithClientProtocolVersions sets the protocol versions supported by the client
+func WithClientProtocolVersions(versions ...ProtocolVersion) ClientOption {
+	return func(opts *clientOptions) {
+		opts.protocolVersions = versions
+	}
+}
+
+// WithClientCapabilities sets the protocol capabilities enabled on the client
+func WithClientCapabilities(capabilities ...Capability) ClientOption {
+	return func(opts *clientOptions) {
+		opts.capabilities = capabilities
+	}
+}
+
+// WithClientStateStore saves every received state to the store and loads it on startup,
+// so the group state can be recovered even if both the server and the client restart
+func WithClientStateStore(store ClientStateStore) ClientOption {
+	return func(opts *clientOptions) {
+		opts.stateStore = store
+	}
+}
+
+// WithClientStateFile is WithClientStateStore with a FileStateStore
+func WithClientStateFile(path string) ClientOption {
+	return WithClientStateStore(NewFileStateStore(path))
+}
+
+// WithClientClock sets the clock used for waiting between retries, default is SystemClock
+func WithClientClock(clock Clock) ClientOption {
+	return func(opts *clientOptions) {
+		opts.clock = clock
+	}
+}
+
+// WithClientConnectionListener sets the listener called on every change of the connection state
+func WithClientConnectionListener(listener ClientConnectionListener) ClientOption {
+	return func(opts *clientOptions) {
+		opts.connListener = listener
+	}
+}
+
+// WithClientSafeOwnership revokes the partitions running on the node, by calling the partition listener
+// with an empty owner, after the client is disconnected longer than bound.
+// bound should be less than the node expired duration of the server, so the partitions are stopped
+// before the server moves them to other nodes. The partitions are not revoked after RunContext returned
+func WithClientSafeOwnership(bound time.Duration) ClientOption {
+	return func(opts *clientOptions) {
+		opts.safeOwnership = bound
+	}
+}
+
+// WithClientBackoff sets the backoff policy between reconnect attempts, overrides WithClientRetryDuration.
+// The zero fields of the policy are normalized, see BackoffPolicy
+func WithClientBackoff(policy BackoffPolicy) ClientOption {
+	return func(opts *clientOptions) {
+		opts.backoff = &policy
+	}
+}
+
+// WithClientRouter updates the router with every group data received by the client
+func WithClientRouter(router *Router) ClientOption {
+	return func(opts *clientOptions) {
+		opts.router = router
+	}
+}
+
+func (o clientOptions) backoffPolicy() BackoffPolicy {
+	if o.backoff != nil {
+		return o.backoff.normalize(o.retryDuration)
+	}
+	return fixedBackoffPolicy(o.retryDuration)
+}
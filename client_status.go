@@ -0,0 +1,133 @@
+package linken
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// ClientPartition is a partition owned by the node, or being moved to the node
+type ClientPartition struct {
+	ID PartitionID `json:"id"`
+	PartitionInfo
+}
+
+// ClientSnapshot is the state of a client at a point in time, returned by Snapshot
+type ClientSnapshot struct {
+	NodeName  string `json:"nodeName"`
+	Connected bool   `json:"connected"`
+
+	// Version is the version of the latest group data received, 0 if not received any
+	Version    GroupVersion      `json:"version"`
+	Nodes      []string          `json:"nodes"`
+	Partitions []ClientPartition `json:"partitions"`
+
+	// PendingNotify is the notify list computed but not yet sent to the server
+	PendingNotify []NotifyPartitionData `json:"pendingNotify"`
+
+	// ProtocolVersion and Capabilities are negotiated by the latest connection of WebsocketClient,
+	// 0 and empty if not negotiated yet
+	ProtocolVersion ProtocolVersion `json:"protocolVersion,omitempty"`
+	Capabilities    []Capability    `json:"capabilities,omitempty"`
+}
+
+// RunningCount returns the number of partitions running on the node
+func (s ClientSnapshot) RunningCount() int {
+	count := 0
+	for _, p := range s.Partitions {
+		if p.Status == PartitionStatusRunning && p.Owner == s.NodeName {
+			count++
+		}
+	}
+	return count
+}
+
+// clientStatus is the state read by Snapshot from other goroutines
+type clientStatus struct {
+	mut       sync.Mutex
+	connected bool
+	data      GroupData
+	pending   []NotifyPartitionData
+	protocol  protocolInfo
+}
+
+func (s *clientStatus) setProtocol(protocol protocolInfo) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.protocol = protocol
+}
+
+func (s *clientStatus) setData(data GroupData) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.connected = true
+	s.data = data
+}
+
+func (s *clientStatus) disconnected() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.connected = false
+	s.pending = nil
+}
+
+func (s *clientStatus) addPending(notifyList []NotifyPartitionData) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.pending = append(s.pending, notifyList...)
+}
+
+// removePending removes the first n entries, they are sent in the same order they are added
+func (s *clientStatus) removePending(n int) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if n > len(s.pending) {
+		n = len(s.pending)
+	}
+	s.pending = append([]NotifyPartitionData(nil), s.pending[n:]...)
+}
+
+func (s *clientStatus) snapshot(nodeName string) ClientSnapshot {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var partitions []ClientPartition
+	for i, p := range s.data.Partitions {
+		if p.Owner == nodeName || p.NextOwner == nodeName {
+			partitions = append(partitions, ClientPartition{ID: PartitionID(i), PartitionInfo: p})
+		}
+	}
+
+	return ClientSnapshot{
+		NodeName:  nodeName,
+		Connected: s.connected,
+
+		Version:    s.data.Version,
+		Nodes:      append([]string(nil), s.data.Nodes...),
+		Partitions: partitions,
+
+		PendingNotify: append([]NotifyPartitionData(nil), s.pending...),
+
+		ProtocolVersion: s.protocol.version,
+		Capabilities:    append([]Capability(nil), s.protocol.capabilities...),
+	}
+}
+
+// Snapshot returns the current state of the client, safe to be called from any goroutine
+func (c *clientCore) Snapshot() ClientSnapshot {
+	return c.status.snapshot(c.nodeName)
+}
+
+type clientDebugHandler struct {
+	client Client
+}
+
+// NewClientDebugHandler returns a handler replying the Snapshot of the client as JSON
+func NewClientDebugHandler(client Client) http.Handler {
+	return clientDebugHandler{client: client}
+}
+
+func (h clientDebugHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.client.Snapshot())
+}
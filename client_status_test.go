@@ -0,0 +1,88 @@
+package linken
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientCore_Snapshot(t *testing.T) {
+	c := newClientCore("node02", 3)
+
+	assert.Equal(t, ClientSnapshot{NodeName: "node02"}, c.Snapshot())
+
+	notifyList := c.handleGroupData(GroupData{
+		Version: 4,
+		Nodes:   []string{"node01", "node02"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 3},
+			{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 4},
+		},
+	})
+	c.status.addPending(notifyList)
+
+	snapshot := c.Snapshot()
+	assert.Equal(t, ClientSnapshot{
+		NodeName:  "node02",
+		Connected: true,
+		Version:   4,
+		Nodes:     []string{"node01", "node02"},
+		Partitions: []ClientPartition{
+			{
+				ID:            1,
+				PartitionInfo: PartitionInfo{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 3},
+			},
+			{
+				ID:            2,
+				PartitionInfo: PartitionInfo{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 4},
+			},
+		},
+		PendingNotify: []NotifyPartitionData{
+			{Action: NotifyActionTypeRunning, Partition: 2, LastVersion: 4},
+		},
+	}, snapshot)
+	assert.Equal(t, 0, snapshot.RunningCount())
+
+	c.status.removePending(1)
+	c.status.disconnected()
+
+	snapshot = c.Snapshot()
+	assert.Equal(t, false, snapshot.Connected)
+	assert.Equal(t, []NotifyPartitionData(nil), snapshot.PendingNotify)
+	assert.Equal(t, GroupVersion(4), snapshot.Version)
+}
+
+func TestClientDebugHandler(t *testing.T) {
+	l := New()
+	client := NewLocalClient(l, "group01", "node01", 2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	NewClientDebugHandler(client).ServeHTTP(w, httptest.NewRequest("GET", "/debug/linken", nil))
+
+	var snapshot ClientSnapshot
+	err := json.Unmarshal(w.Body.Bytes(), &snapshot)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	assert.Equal(t, true, snapshot.Connected)
+	assert.Equal(t, GroupVersion(2), snapshot.Version)
+	assert.Equal(t, 2, snapshot.RunningCount())
+
+	client.Shutdown()
+	wg.Wait()
+
+	assert.Equal(t, false, client.Snapshot().Connected)
+}
@@ -0,0 +1,153 @@
+package linken
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of Linken and WebsocketClient, replaced by FakeClock in tests
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls fn after the duration d
+	AfterFunc(d time.Duration, fn func()) ClockTimer
+}
+
+// ClockTimer ...
+type ClockTimer interface {
+	// Stop prevents the timer from firing, returns false if the timer already fired or been stopped
+	Stop() bool
+}
+
+// SystemClock is the Clock using the functions of the time package
+type SystemClock struct {
+}
+
+var _ Clock = SystemClock{}
+
+// Now ...
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc ...
+func (SystemClock) AfterFunc(d time.Duration, fn func()) ClockTimer {
+	return time.AfterFunc(d, fn)
+}
+
+// FakeClock is a Clock only moved by Advance, timers are fired synchronously inside Advance
+type FakeClock struct {
+	mut    sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	seq      uint64
+	fn       func()
+}
+
+var _ Clock = &FakeClock{}
+
+// NewFakeClock creates a FakeClock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now ...
+func (c *FakeClock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now
+}
+
+// AfterFunc ...
+func (c *FakeClock) AfterFunc(d time.Duration, fn func()) ClockTimer {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.seq++
+	t := &fakeTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		seq:      c.seq,
+		fn:       fn,
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// PendingTimers returns the number of timers not yet fired or stopped
+func (c *FakeClock) PendingTimers() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return len(c.timers)
+}
+
+// Advance moves the clock forward by d, fires the expired timers in the order of their deadlines.
+// Timers created by the fired functions are also fired if they expire before the new time
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mut.Lock()
+	end := c.now.Add(d)
+	c.mut.Unlock()
+
+	for {
+		t := c.popExpiredTimer(end)
+		if t == nil {
+			break
+		}
+		t.fn()
+	}
+
+	c.mut.Lock()
+	c.now = end
+	c.mut.Unlock()
+}
+
+func (c *FakeClock) popExpiredTimer(end time.Time) *fakeTimer {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	sort.Slice(c.timers, func(i, j int) bool {
+		a, b := c.timers[i], c.timers[j]
+		if a.deadline.Equal(b.deadline) {
+			return a.seq < b.seq
+		}
+		return a.deadline.Before(b.deadline)
+	})
+	if len(c.timers) == 0 || c.timers[0].deadline.After(end) {
+		return nil
+	}
+
+	t := c.timers[0]
+	c.timers = c.removeTimer(t)
+	if t.deadline.After(c.now) {
+		c.now = t.deadline
+	}
+	return t
+}
+
+// removeTimer must be called while holding the lock
+func (c *FakeClock) removeTimer(t *fakeTimer) []*fakeTimer {
+	for i, e := range c.timers {
+		if e == t {
+			copy(c.timers[i:], c.timers[i+1:])
+			c.timers[len(c.timers)-1] = nil
+			return c.timers[:len(c.timers)-1]
+		}
+	}
+	return c.timers
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	prevLen := len(c.timers)
+	c.timers = c.removeTimer(t)
+	return len(c.timers) < prevLen
+}
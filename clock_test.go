@@ -0,0 +1,84 @@
+package linken
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	c := NewFakeClock(start)
+
+	var calls []string
+	c.AfterFunc(20*time.Second, func() {
+		calls = append(calls, "timer02")
+	})
+	c.AfterFunc(10*time.Second, func() {
+		calls = append(calls, "timer01")
+		c.AfterFunc(5*time.Second, func() {
+			calls = append(calls, "timer01-nested")
+		})
+	})
+	stopped := c.AfterFunc(12*time.Second, func() {
+		calls = append(calls, "timer-stopped")
+	})
+	c.AfterFunc(30*time.Second, func() {
+		calls = append(calls, "timer03")
+	})
+
+	assert.Equal(t, true, stopped.Stop())
+	assert.Equal(t, false, stopped.Stop())
+	assert.Equal(t, 3, c.PendingTimers())
+
+	c.Advance(9 * time.Second)
+	assert.Equal(t, []string(nil), calls)
+	assert.Equal(t, start.Add(9*time.Second), c.Now())
+
+	c.Advance(11 * time.Second)
+	assert.Equal(t, []string{"timer01", "timer01-nested", "timer02"}, calls)
+	assert.Equal(t, start.Add(20*time.Second), c.Now())
+	assert.Equal(t, 1, c.PendingTimers())
+}
+
+func TestLinken_Node_Expired_With_FakeClock(t *testing.T) {
+	c := NewFakeClock(time.Now())
+	l := New(WithClock(c), WithNodeExpiredDuration(30*time.Second))
+
+	err := l.Join("group01", "node01", 2, nil)
+	assert.Equal(t, nil, err)
+	err = l.Join("group01", "node02", 2, nil)
+	assert.Equal(t, nil, err)
+
+	l.Disconnect("group01", "node02")
+
+	c.Advance(29 * time.Second)
+	assert.Equal(t, []string{"node01", "node02"}, getCurrentGroupData(l, "group01").Nodes)
+
+	c.Advance(time.Second)
+	assert.Equal(t, []string{"node01"}, getCurrentGroupData(l, "group01").Nodes)
+	assert.Equal(t, 0, c.PendingTimers())
+}
+
+func TestSleepContext_With_FakeClock(t *testing.T) {
+	c := NewFakeClock(time.Now())
+
+	done := make(chan struct{})
+	go func() {
+		sleepContext(context.Background(), c, 10*time.Second)
+		close(done)
+	}()
+
+	for c.PendingTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Advance(10 * time.Second)
+	<-done
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sleepContext(ctx, c, 10*time.Second)
+	assert.Equal(t, 0, c.PendingTimers())
+}
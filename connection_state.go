@@ -0,0 +1,96 @@
+package linken
+
+import (
+	"sync"
+)
+
+// ConnectionState is the state of the connection of WebsocketClient
+type ConnectionState int
+
+const (
+	// ConnectionStateConnected is the connection established and the join command sent
+	ConnectionStateConnected ConnectionState = 1
+	// ConnectionStateJoined is the first group data received, the handshake completed
+	ConnectionStateJoined ConnectionState = 2
+	// ConnectionStateDisconnected is the connection lost or closed
+	ConnectionStateDisconnected ConnectionState = 3
+	// ConnectionStateRetrying is the client waiting for the retry duration before connecting again
+	ConnectionStateRetrying ConnectionState = 4
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateJoined:
+		return "joined"
+	case ConnectionStateDisconnected:
+		return "disconnected"
+	case ConnectionStateRetrying:
+		return "retrying"
+	default:
+		return "unknown"
+	}
+}
+
+// safeOwnership revokes the partitions running on the node after it is disconnected for too long,
+// by calling the partition listener with an empty owner
+type safeOwnership struct {
+	mut   sync.Mutex
+	timer ClockTimer
+}
+
+// stop stops the revoke timer, waits for the running revocation if any
+func (s *safeOwnership) stop() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+func (c *WebsocketClient) setConnectionState(state ConnectionState) {
+	c.options.connListener(state)
+
+	bound := c.options.safeOwnership
+	if bound <= 0 {
+		return
+	}
+
+	switch state {
+	case ConnectionStateJoined:
+		c.safety.stop()
+
+	case ConnectionStateDisconnected:
+		c.safety.mut.Lock()
+		defer c.safety.mut.Unlock()
+
+		// stopped by Shutdown or the context of RunContext, the partitions are not revoked after RunContext returned
+		if c.rootCtx.Err() != nil {
+			return
+		}
+		if c.safety.timer != nil || c.status.snapshot(c.nodeName).Version == 0 {
+			return
+		}
+		c.safety.timer = c.options.clock.AfterFunc(bound, c.revokePartitions)
+	}
+}
+
+func (c *WebsocketClient) revokePartitions() {
+	c.safety.mut.Lock()
+	defer c.safety.mut.Unlock()
+
+	if c.safety.timer == nil {
+		return
+	}
+	c.safety.timer = nil
+
+	c.options.logger.Warn("Disconnected for too long, revoke running partitions")
+	for _, p := range c.status.snapshot(c.nodeName).Partitions {
+		if p.Status == PartitionStatusRunning && p.Owner == c.nodeName {
+			c.options.partitionListener(p.ID, "")
+		}
+	}
+}
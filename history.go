@@ -0,0 +1,228 @@
+package linken
+
+import (
+	"sort"
+)
+
+// GroupEventType ...
+type GroupEventType string
+
+const (
+	// GroupEventTypeNodeJoined is a node joined the group, or reconnected while being a zombie
+	GroupEventTypeNodeJoined GroupEventType = "node_joined"
+	// GroupEventTypeNodeLeft is a node left the group or expired
+	GroupEventTypeNodeLeft GroupEventType = "node_left"
+	// GroupEventTypeNodeZombie is a node disconnected, it is still in the group until expired
+	GroupEventTypeNodeZombie GroupEventType = "node_zombie"
+	// GroupEventTypePartitionChanged is a partition transition
+	GroupEventTypePartitionChanged GroupEventType = "partition_changed"
+	// GroupEventTypePartitionTimeout is a partition forced to move after stuck in Starting or Stopping,
+	// it is followed by the partition_changed events caused by it
+	GroupEventTypePartitionTimeout GroupEventType = "partition_timeout"
+)
+
+// PartitionTransition ...
+type PartitionTransition struct {
+	ID  PartitionID   `json:"id"`
+	Old PartitionInfo `json:"old"`
+	New PartitionInfo `json:"new"`
+}
+
+// GroupEvent is a single change of a group. Node zombie and node reconnect events do not increase
+// the group version, they are tagged with the version of the group at the time they happened,
+// use a HistoryCursor to replay them
+type GroupEvent struct {
+	Version   GroupVersion         `json:"version"`
+	Type      GroupEventType       `json:"type"`
+	Node      string               `json:"node,omitempty"`
+	Partition *PartitionTransition `json:"partition,omitempty"`
+}
+
+// EventSeq is the position of an event in the history of a group, starting from 1 and increased by 1 for every event
+type EventSeq uint64
+
+// GroupEpoch identifies a lifetime of a group, it changes when the group is deleted and created again,
+// or recovered from a previous state after the server restarted. 0 means unknown
+type GroupEpoch uint64
+
+// HistoryCursor is a position in the history of a group, a cursor of another epoch is not a position of the group.
+// Seq is the sequence of the next event to receive, if it is 0 the position is right after the group data with Version
+type HistoryCursor struct {
+	Epoch   GroupEpoch   `json:"epoch"`
+	Version GroupVersion `json:"version,omitempty"`
+	Seq     EventSeq     `json:"seq,omitempty"`
+}
+
+// HistoryCursor returns the position right after the group data, to receive the events not reflected in it
+func (d GroupData) HistoryCursor() HistoryCursor {
+	return HistoryCursor{Epoch: d.Epoch, Version: d.Version}
+}
+
+// GroupHistory is the result of Linken.History, NextSeq is the sequence of the next event.
+// Snapshot is set instead of Events if the history since the requested cursor is not available anymore
+// or the cursor is of another epoch, it is the state right before the event with sequence NextSeq
+type GroupHistory struct {
+	Epoch    GroupEpoch   `json:"epoch"`
+	Version  GroupVersion `json:"version"`
+	NextSeq  EventSeq     `json:"nextSeq"`
+	Events   []GroupEvent `json:"events,omitempty"`
+	Snapshot *GroupData   `json:"snapshot,omitempty"`
+}
+
+// Next returns the cursor of the events after the history
+func (h GroupHistory) Next() HistoryCursor {
+	return HistoryCursor{Epoch: h.Epoch, Seq: h.NextSeq}
+}
+
+// groupHistory is a ring buffer of the latest events,
+// the sequence of the i-th event in the buffer is nextSeq - count + i
+type groupHistory struct {
+	events []GroupEvent
+	start  int
+	count  int
+
+	nextSeq EventSeq
+
+	// versions are the published group versions in increasing order,
+	// with the sequence of the first event after each of them, only kept while that event is kept
+	versions []versionSeq
+}
+
+type versionSeq struct {
+	version GroupVersion
+	seq     EventSeq
+}
+
+func newGroupHistory(size int) groupHistory {
+	return groupHistory{
+		events:  make([]GroupEvent, size),
+		nextSeq: 1,
+	}
+}
+
+func (h *groupHistory) append(e GroupEvent) {
+	h.nextSeq++
+	defer h.dropVersions()
+
+	if len(h.events) == 0 {
+		return
+	}
+
+	if h.count < len(h.events) {
+		h.events[(h.start+h.count)%len(h.events)] = e
+		h.count++
+		return
+	}
+
+	h.events[h.start] = e
+	h.start = (h.start + 1) % len(h.events)
+}
+
+func (h *groupHistory) oldestSeq() EventSeq {
+	return h.nextSeq - EventSeq(h.count)
+}
+
+// published records the group data with the version is sent to the watchers,
+// a version published again keeps the sequence of its first publication
+func (h *groupHistory) published(version GroupVersion) {
+	n := len(h.versions)
+	if n > 0 && h.versions[n-1].version >= version {
+		return
+	}
+	h.versions = append(h.versions, versionSeq{version: version, seq: h.nextSeq})
+	h.dropVersions()
+}
+
+// dropVersions removes the versions whose next events are dropped,
+// at most one version per kept event (and one for the current version) is kept
+func (h *groupHistory) dropVersions() {
+	oldest := h.oldestSeq()
+	drop := 0
+	for drop < len(h.versions) && (h.versions[drop].seq < oldest || len(h.versions)-drop > h.count+1) {
+		drop++
+	}
+	if drop == 0 {
+		return
+	}
+	h.versions = append(h.versions[:0], h.versions[drop:]...)
+}
+
+// seqAfterVersion returns the sequence of the first event after the group data with the version was published
+func (h *groupHistory) seqAfterVersion(version GroupVersion) (EventSeq, bool) {
+	i := sort.Search(len(h.versions), func(i int) bool {
+		return h.versions[i].version >= version
+	})
+	if i >= len(h.versions) || h.versions[i].version != version {
+		return 0, false
+	}
+	return h.versions[i].seq, true
+}
+
+// since returns the events with sequence >= fromSeq,
+// ok = false if some of them were dropped or fromSeq is greater than the next sequence
+func (h *groupHistory) since(fromSeq EventSeq) (events []GroupEvent, ok bool) {
+	oldest := h.oldestSeq()
+	if fromSeq < oldest || fromSeq > h.nextSeq {
+		return nil, false
+	}
+	for i := int(fromSeq - oldest); i < h.count; i++ {
+		events = append(events, h.events[(h.start+i)%len(h.events)])
+	}
+	return events, true
+}
+
+// sinceCursor is since for a cursor of the epoch, ok = false if the cursor is of another epoch
+func (h *groupHistory) sinceCursor(epoch GroupEpoch, cursor HistoryCursor) (events []GroupEvent, ok bool) {
+	if cursor.Epoch == 0 || cursor.Epoch != epoch {
+		return nil, false
+	}
+	fromSeq := cursor.Seq
+	if fromSeq == 0 {
+		fromSeq, ok = h.seqAfterVersion(cursor.Version)
+		if !ok {
+			return nil, false
+		}
+	}
+	return h.since(fromSeq)
+}
+
+// diffGroupEvents computes the events changing prev to current, both node lists must be sorted
+func diffGroupEvents(prev GroupData, current GroupData) []GroupEvent {
+	var events []GroupEvent
+	version := current.Version
+
+	i, j := 0, 0
+	for i < len(prev.Nodes) || j < len(current.Nodes) {
+		switch {
+		case j >= len(current.Nodes) || (i < len(prev.Nodes) && prev.Nodes[i] < current.Nodes[j]):
+			events = append(events, GroupEvent{Version: version, Type: GroupEventTypeNodeLeft, Node: prev.Nodes[i]})
+			i++
+		case i >= len(prev.Nodes) || current.Nodes[j] < prev.Nodes[i]:
+			events = append(events, GroupEvent{Version: version, Type: GroupEventTypeNodeJoined, Node: current.Nodes[j]})
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+
+	for id, p := range current.Partitions {
+		var old PartitionInfo
+		if id < len(prev.Partitions) {
+			old = prev.Partitions[id]
+		}
+		if old == p {
+			continue
+		}
+		events = append(events, GroupEvent{
+			Version: version,
+			Type:    GroupEventTypePartitionChanged,
+			Partition: &PartitionTransition{
+				ID:  PartitionID(id),
+				Old: old,
+				New: p,
+			},
+		})
+	}
+	return events
+}
@@ -0,0 +1,173 @@
+package linken
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGroupHistory(t *testing.T) {
+	h := newGroupHistory(3)
+
+	events, ok := h.since(0)
+	assert.Equal(t, false, ok)
+	assert.Equal(t, []GroupEvent(nil), events)
+
+	events, ok = h.since(1)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent(nil), events)
+
+	h.append(GroupEvent{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"})
+	h.append(GroupEvent{Version: 2, Type: GroupEventTypeNodeJoined, Node: "node02"})
+	assert.Equal(t, EventSeq(3), h.nextSeq)
+
+	events, ok = h.since(1)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent{
+		{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"},
+		{Version: 2, Type: GroupEventTypeNodeJoined, Node: "node02"},
+	}, events)
+
+	// events not increasing the version are still replayed after the events already seen
+	h.append(GroupEvent{Version: 2, Type: GroupEventTypeNodeZombie, Node: "node01"})
+
+	events, ok = h.since(3)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent{
+		{Version: 2, Type: GroupEventTypeNodeZombie, Node: "node01"},
+	}, events)
+
+	h.append(GroupEvent{Version: 3, Type: GroupEventTypeNodeLeft, Node: "node01"})
+
+	_, ok = h.since(1)
+	assert.Equal(t, false, ok)
+
+	events, ok = h.since(2)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent{
+		{Version: 2, Type: GroupEventTypeNodeJoined, Node: "node02"},
+		{Version: 2, Type: GroupEventTypeNodeZombie, Node: "node01"},
+		{Version: 3, Type: GroupEventTypeNodeLeft, Node: "node01"},
+	}, events)
+
+	events, ok = h.since(5)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent(nil), events)
+
+	// not a sequence of this history, e.g. the group was deleted and created again
+	_, ok = h.since(6)
+	assert.Equal(t, false, ok)
+}
+
+func TestGroupHistory_Disabled(t *testing.T) {
+	h := newGroupHistory(0)
+	h.append(GroupEvent{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"})
+
+	_, ok := h.since(1)
+	assert.Equal(t, false, ok)
+
+	events, ok := h.since(2)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent(nil), events)
+}
+
+func TestGroupHistory_Since_Cursor(t *testing.T) {
+	h := newGroupHistory(3)
+	h.published(0)
+
+	h.append(GroupEvent{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"})
+	h.published(1)
+	h.append(GroupEvent{Version: 1, Type: GroupEventTypeNodeZombie, Node: "node01"})
+	// published again after the zombie event
+	h.published(1)
+
+	events, ok := h.sinceCursor(5, HistoryCursor{Epoch: 5, Version: 0})
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent{
+		{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"},
+		{Version: 1, Type: GroupEventTypeNodeZombie, Node: "node01"},
+	}, events)
+
+	events, ok = h.sinceCursor(5, HistoryCursor{Epoch: 5, Version: 1})
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent{
+		{Version: 1, Type: GroupEventTypeNodeZombie, Node: "node01"},
+	}, events)
+
+	events, ok = h.sinceCursor(5, HistoryCursor{Epoch: 5, Version: 1, Seq: 3})
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent(nil), events)
+
+	// another epoch
+	_, ok = h.sinceCursor(5, HistoryCursor{Epoch: 4, Version: 1})
+	assert.Equal(t, false, ok)
+	_, ok = h.sinceCursor(5, HistoryCursor{Version: 1})
+	assert.Equal(t, false, ok)
+
+	// not published version
+	_, ok = h.sinceCursor(5, HistoryCursor{Epoch: 5, Version: 2})
+	assert.Equal(t, false, ok)
+
+	h.append(GroupEvent{Version: 2, Type: GroupEventTypeNodeLeft, Node: "node01"})
+	h.published(2)
+	h.append(GroupEvent{Version: 3, Type: GroupEventTypeNodeJoined, Node: "node02"})
+	h.published(3)
+
+	// the first event after version 0 is dropped
+	assert.Equal(t, []versionSeq{{version: 1, seq: 2}, {version: 2, seq: 4}, {version: 3, seq: 5}}, h.versions)
+	_, ok = h.sinceCursor(5, HistoryCursor{Epoch: 5, Version: 0})
+	assert.Equal(t, false, ok)
+
+	events, ok = h.sinceCursor(5, HistoryCursor{Epoch: 5, Version: 2})
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent{
+		{Version: 3, Type: GroupEventTypeNodeJoined, Node: "node02"},
+	}, events)
+}
+
+func TestGroupHistory_Disabled_Since_Cursor(t *testing.T) {
+	h := newGroupHistory(0)
+	h.published(0)
+	h.append(GroupEvent{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"})
+	h.published(1)
+
+	_, ok := h.sinceCursor(1, HistoryCursor{Epoch: 1, Version: 0})
+	assert.Equal(t, false, ok)
+
+	events, ok := h.sinceCursor(1, HistoryCursor{Epoch: 1, Version: 1})
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []GroupEvent(nil), events)
+	assert.Equal(t, 1, len(h.versions))
+}
+
+func TestDiffGroupEvents(t *testing.T) {
+	prev := GroupData{
+		Version: 3,
+		Nodes:   []string{"node01", "node03"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusRunning, Owner: "node03", ModVersion: 3},
+		},
+	}
+	current := GroupData{
+		Version: 4,
+		Nodes:   []string{"node01", "node02"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 4},
+		},
+	}
+
+	assert.Equal(t, []GroupEvent{
+		{Version: 4, Type: GroupEventTypeNodeJoined, Node: "node02"},
+		{Version: 4, Type: GroupEventTypeNodeLeft, Node: "node03"},
+		{
+			Version: 4,
+			Type:    GroupEventTypePartitionChanged,
+			Partition: &PartitionTransition{
+				ID:  1,
+				Old: PartitionInfo{Status: PartitionStatusRunning, Owner: "node03", ModVersion: 3},
+				New: PartitionInfo{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 4},
+			},
+		},
+	}, diffGroupEvents(prev, current))
+}
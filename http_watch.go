@@ -0,0 +1,349 @@
+package linken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (h *WebsocketHandler) writeHTTPJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		h.options.logger.Error("Error while write JSON response", zap.Error(err))
+	}
+}
+
+func (h *WebsocketHandler) writeHTTPError(w http.ResponseWriter, code ServerErrorCode, err error) {
+	status := http.StatusBadRequest
+	switch code {
+	case ServerErrorCodeUnauthorized:
+		status = http.StatusForbidden
+	case ServerErrorCodeInternal:
+		status = http.StatusInternalServerError
+	}
+	h.writeHTTPJSON(w, status, serverErrorReply{Error: newServerError(code, err)})
+}
+
+func readonlyErrorCode(err error) ServerErrorCode {
+	if errors.Is(err, errGroupSecretNotExisted) || errors.Is(err, errInvalidReadSecret) {
+		return ServerErrorCodeUnauthorized
+	}
+	return ServerErrorCodeInvalidCommand
+}
+
+func parsePollTimeout(s string, maxTimeout time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return maxTimeout, nil
+	}
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.New("invalid 'timeout' param")
+	}
+	if timeout <= 0 || timeout > maxTimeout {
+		return maxTimeout, nil
+	}
+	return timeout, nil
+}
+
+func parseFromVersion(s string) (GroupVersion, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	version, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid 'fromVersion' param")
+	}
+	return GroupVersion(version), nil
+}
+
+func parseUintParam(s string, name string) (uint64, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid '" + name + "' param")
+	}
+	return value, nil
+}
+
+// parseHistoryCursor parses the query params 'epoch', 'fromSeq' and 'fromVersion', see HistoryCursor
+func parseHistoryCursor(query url.Values) (HistoryCursor, error) {
+	epoch, err := parseUintParam(query.Get("epoch"), "epoch")
+	if err != nil {
+		return HistoryCursor{}, err
+	}
+	fromSeq, err := parseUintParam(query.Get("fromSeq"), "fromSeq")
+	if err != nil {
+		return HistoryCursor{}, err
+	}
+	fromVersion, err := parseFromVersion(query.Get("fromVersion"))
+	if err != nil {
+		return HistoryCursor{}, err
+	}
+	return HistoryCursor{
+		Epoch:   GroupEpoch(epoch),
+		Version: fromVersion,
+		Seq:     EventSeq(fromSeq),
+	}, nil
+}
+
+func isHistoryRequested(query url.Values) bool {
+	history, _ := strconv.ParseBool(query.Get("history"))
+	return history
+}
+
+func (h *WebsocketHandler) pollFunc(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	req := ServerWatchRequest{
+		GroupName: query.Get("group"),
+		Secret:    query.Get("secret"),
+	}
+	err := validateReadonlyCommand(req, h.options.groupSecrets)
+	if err != nil {
+		h.writeHTTPError(w, readonlyErrorCode(err), err)
+		return
+	}
+
+	timeout, err := parsePollTimeout(query.Get("timeout"), h.options.maxPollTimeout)
+	if err != nil {
+		h.writeHTTPError(w, ServerErrorCodeInvalidCommand, err)
+		return
+	}
+
+	mergedCtx, mergedCancel := mergeContext(r.Context(), h.rootCtx)
+	defer mergedCancel()
+
+	ctx, cancel := context.WithTimeout(mergedCtx, timeout)
+	defer cancel()
+
+	if isHistoryRequested(query) {
+		h.pollHistory(ctx, w, req.GroupName, query)
+		return
+	}
+
+	fromVersion, err := parseFromVersion(query.Get("fromVersion"))
+	if err != nil {
+		h.writeHTTPError(w, ServerErrorCodeInvalidCommand, err)
+		return
+	}
+
+	ch := make(chan GroupData, 1)
+	h.linken.Watch(req.GroupName, WatchRequest{
+		FromVersion:  fromVersion,
+		ResponseChan: ch,
+	})
+
+	select {
+	case data := <-ch:
+		h.writeHTTPJSON(w, http.StatusOK, data)
+	case <-ctx.Done():
+		h.linken.RemoveWatch(req.GroupName, ch)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *WebsocketHandler) pollHistory(ctx context.Context, w http.ResponseWriter, groupName string, query url.Values) {
+	cursor, err := parseHistoryCursor(query)
+	if err != nil {
+		h.writeHTTPError(w, ServerErrorCodeInvalidCommand, err)
+		return
+	}
+
+	history, ok := <-h.linken.WatchHistory(ctx, groupName, cursor)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.writeHTTPJSON(w, http.StatusOK, history)
+}
+
+// Poll returns the long-poll watch endpoint, accepting GET requests with query params
+// 'group', 'secret' (read secret), 'fromVersion' and 'timeout' (e.g. 10s).
+// It responds the group data as JSON when the group version >= fromVersion,
+// or responds 204 No Content after the timeout.
+// With the query param 'history=true', it responds the GroupHistory after the cursor of the params
+// 'epoch', 'fromSeq' and 'fromVersion' when there are events after it, see Linken.WatchHistory
+func (h *WebsocketHandler) Poll() http.Handler {
+	return http.HandlerFunc(h.pollFunc)
+}
+
+// eventsFromVersion uses the Last-Event-ID header (the version of the last received event) for resumption,
+// otherwise uses the 'fromVersion' query param
+func eventsFromVersion(r *http.Request) (GroupVersion, error) {
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if len(lastEventID) == 0 {
+		return parseFromVersion(r.URL.Query().Get("fromVersion"))
+	}
+
+	lastVersion, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid 'Last-Event-ID' header")
+	}
+	return GroupVersion(lastVersion + 1), nil
+}
+
+// eventsHistoryCursor uses the Last-Event-ID header (the epoch and the next sequence of the last received
+// history, separated by '-') for resumption, otherwise uses the cursor of the query params
+func eventsHistoryCursor(r *http.Request) (HistoryCursor, error) {
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if len(lastEventID) == 0 {
+		return parseHistoryCursor(r.URL.Query())
+	}
+
+	parts := strings.Split(lastEventID, "-")
+	if len(parts) != 2 {
+		return HistoryCursor{}, errors.New("invalid 'Last-Event-ID' header")
+	}
+	epoch, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return HistoryCursor{}, errors.New("invalid 'Last-Event-ID' header")
+	}
+	nextSeq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return HistoryCursor{}, errors.New("invalid 'Last-Event-ID' header")
+	}
+	return HistoryCursor{Epoch: GroupEpoch(epoch), Seq: EventSeq(nextSeq)}, nil
+}
+
+func (h *WebsocketHandler) writeEvent(
+	w http.ResponseWriter, flusher http.Flusher, id string, event string, v interface{},
+) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write([]byte("id: " + id + "\nevent: " + event + "\ndata: " + string(content) + "\n\n"))
+	if err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+func historyEventID(history GroupHistory) string {
+	return strconv.FormatUint(uint64(history.Epoch), 10) + "-" + strconv.FormatUint(uint64(history.NextSeq), 10)
+}
+
+func (h *WebsocketHandler) eventsFunc(w http.ResponseWriter, r *http.Request) {
+	logger := h.options.logger
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	req := ServerWatchRequest{
+		GroupName: query.Get("group"),
+		Secret:    query.Get("secret"),
+	}
+	err := validateReadonlyCommand(req, h.options.groupSecrets)
+	if err != nil {
+		h.writeHTTPError(w, readonlyErrorCode(err), err)
+		return
+	}
+
+	historyRequested := isHistoryRequested(query)
+
+	var fromVersion GroupVersion
+	var cursor HistoryCursor
+	if historyRequested {
+		cursor, err = eventsHistoryCursor(r)
+	} else {
+		fromVersion, err = eventsFromVersion(r)
+	}
+	if err != nil {
+		h.writeHTTPError(w, ServerErrorCodeInvalidCommand, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeHTTPError(w, ServerErrorCodeInternal, errors.New("streaming not supported"))
+		return
+	}
+
+	ctx, cancel := mergeContext(r.Context(), h.rootCtx)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if historyRequested {
+		for history := range h.linken.WatchHistory(ctx, req.GroupName, cursor) {
+			err := h.writeEvent(w, flusher, historyEventID(history), "history", history)
+			if err != nil {
+				logger.Error("Error while write event", zap.Error(err))
+				return
+			}
+		}
+		return
+	}
+
+	for data := range h.linken.WatchContext(ctx, req.GroupName, fromVersion) {
+		err := h.writeEvent(w, flusher, strconv.FormatUint(uint64(data.Version), 10), "group", data)
+		if err != nil {
+			logger.Error("Error while write event", zap.Error(err))
+			return
+		}
+	}
+}
+
+// Events returns the Server-Sent Events endpoint, accepting GET requests with query params
+// 'group', 'secret' (read secret) and 'fromVersion'. Each event has the group version as its id,
+// and the Last-Event-ID header is used instead of 'fromVersion' when reconnecting.
+// With the query param 'history=true', it sends 'history' events of GroupHistory after the cursor of the params
+// 'epoch', 'fromSeq' and 'fromVersion', with the epoch and the next sequence as the id, see Linken.WatchHistory
+func (h *WebsocketHandler) Events() http.Handler {
+	return http.HandlerFunc(h.eventsFunc)
+}
+
+func (h *WebsocketHandler) historyFunc(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	req := ServerWatchRequest{
+		GroupName: query.Get("group"),
+		Secret:    query.Get("secret"),
+	}
+	err := validateReadonlyCommand(req, h.options.groupSecrets)
+	if err != nil {
+		h.writeHTTPError(w, readonlyErrorCode(err), err)
+		return
+	}
+
+	cursor, err := parseHistoryCursor(query)
+	if err != nil {
+		h.writeHTTPError(w, ServerErrorCodeInvalidCommand, err)
+		return
+	}
+
+	h.writeHTTPJSON(w, http.StatusOK, h.linken.History(req.GroupName, cursor))
+}
+
+// History returns the event replay endpoint, accepting GET requests with query params
+// 'group', 'secret' (read secret), 'epoch', 'fromSeq' and 'fromVersion'. It responds the events after the cursor
+// of the params, or the snapshot of the current state if the history after it is truncated, see Linken.History
+func (h *WebsocketHandler) History() http.Handler {
+	return http.HandlerFunc(h.historyFunc)
+}
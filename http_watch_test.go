@@ -0,0 +1,331 @@
+package linken
+
+import (
+	"bufio"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func servePollForTest(h *WebsocketHandler, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.Poll().ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w
+}
+
+func TestWebsocketHandler_Poll_Current_State(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	w := servePollForTest(h, "/poll?group=group01&fromVersion=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t,
+		`{"version":1,"epoch":1,"nodes":["node01"],"partitions":[{"status":1,"owner":"node01","nextOwner":"","modVersion":1}]}`,
+		strings.TrimSpace(w.Body.String()))
+}
+
+func TestWebsocketHandler_Poll_Wait_For_Newer_Version(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = h.linken.Join("group01", "node02", 1, nil)
+	}()
+
+	w := servePollForTest(h, "/poll?group=group01&fromVersion=2&timeout=1s")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t,
+		`{"version":2,"epoch":1,"nodes":["node01","node02"],"partitions":[{"status":1,"owner":"node01","nextOwner":"","modVersion":1}]}`,
+		strings.TrimSpace(w.Body.String()))
+}
+
+func TestWebsocketHandler_Poll_Timeout(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	w := servePollForTest(h, "/poll?group=group01&fromVersion=2&timeout=20ms")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "", w.Body.String())
+
+	h.linken.mut.RLock()
+	assert.Equal(t, 0, len(h.linken.groups["group01"].waitList))
+	h.linken.mut.RUnlock()
+}
+
+func TestWebsocketHandler_Poll_Errors(t *testing.T) {
+	h := NewWebsocketHandler(WithGroupSecret("group01", GroupSecret{Read: "read-secret"}))
+	defer h.Shutdown()
+
+	w := servePollForTest(h, "/poll?group=group01&secret=wrong")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t,
+		`{"error":{"code":"unauthorized","message":"invalid 'secret' for read permission"}}`,
+		strings.TrimSpace(w.Body.String()))
+
+	w = servePollForTest(h, "/poll?secret=read-secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t,
+		`{"error":{"code":"invalid_command","message":"groupName must not be empty"}}`,
+		strings.TrimSpace(w.Body.String()))
+
+	w = servePollForTest(h, "/poll?group=group01&secret=read-secret&fromVersion=abc")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t,
+		`{"error":{"code":"invalid_command","message":"invalid 'fromVersion' param"}}`,
+		strings.TrimSpace(w.Body.String()))
+
+	w = servePollForTest(h, "/poll?group=group01&secret=read-secret&timeout=abc")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestParsePollTimeout(t *testing.T) {
+	d, err := parsePollTimeout("", 30*time.Second)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 30*time.Second, d)
+
+	d, err = parsePollTimeout("5s", 30*time.Second)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 5*time.Second, d)
+
+	d, err = parsePollTimeout("1m", 30*time.Second)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 30*time.Second, d)
+}
+
+func readEventForTest(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		assert.Equal(t, nil, err)
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestWebsocketHandler_Events(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	server := httptest.NewServer(h.Events())
+	defer server.Close()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	resp, err := http.Get(server.URL + "?group=group01")
+	assert.Equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, `id: 1
+event: group
+data: {"version":1,"epoch":1,"nodes":["node01"],"partitions":[{"status":1,"owner":"node01","nextOwner":"","modVersion":1}]}
+`, readEventForTest(t, reader))
+
+	err = h.linken.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, `id: 2
+event: group
+data: {"version":2,"epoch":1,"nodes":["node01","node02"],"partitions":[{"status":1,"owner":"node01","nextOwner":"","modVersion":1}]}
+`, readEventForTest(t, reader))
+}
+
+func TestWebsocketHandler_Events_Last_Event_ID(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	server := httptest.NewServer(h.Events())
+	defer server.Close()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	err = h.linken.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"?group=group01&fromVersion=1", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("Last-Event-ID", "2")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		h.linken.Leave("group01", "node02")
+	}()
+
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, `id: 3
+event: group
+data: {"version":3,"epoch":1,"nodes":["node01"],"partitions":[{"status":1,"owner":"node01","nextOwner":"","modVersion":1}]}
+`, readEventForTest(t, reader))
+}
+
+func TestWebsocketHandler_Events_Missing_Read_Secret(t *testing.T) {
+	h := NewWebsocketHandler(WithGroupSecret("group01", GroupSecret{Read: "read-secret"}))
+	defer h.Shutdown()
+
+	w := httptest.NewRecorder()
+	h.Events().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events?group=group01", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t,
+		`{"error":{"code":"unauthorized","message":"invalid 'secret' for read permission"}}`,
+		strings.TrimSpace(w.Body.String()))
+}
+
+func TestWebsocketHandler_History(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	err = h.linken.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+
+	w := httptest.NewRecorder()
+	h.History().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/history?group=group01&epoch=1&fromSeq=3", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t,
+		`{"epoch":1,"version":2,"nextSeq":4,"events":[{"version":2,"type":"node_joined","node":"node02"}]}`,
+		strings.TrimSpace(w.Body.String()))
+
+	w = httptest.NewRecorder()
+	h.History().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/history?group=group01&epoch=1&fromVersion=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t,
+		`{"epoch":1,"version":2,"nextSeq":4,"events":[{"version":2,"type":"node_joined","node":"node02"}]}`,
+		strings.TrimSpace(w.Body.String()))
+
+	w = httptest.NewRecorder()
+	h.History().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/history?group=group01", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t,
+		`{"epoch":1,"version":2,"nextSeq":4,"snapshot":{"version":2,"epoch":1,"nodes":["node01","node02"],`+
+			`"partitions":[{"status":1,"owner":"node01","nextOwner":"","modVersion":1}]}}`,
+		strings.TrimSpace(w.Body.String()))
+
+	w = httptest.NewRecorder()
+	h.History().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/history?group=group01&epoch=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t,
+		`{"error":{"code":"invalid_command","message":"invalid 'epoch' param"}}`,
+		strings.TrimSpace(w.Body.String()))
+}
+
+func TestWebsocketHandler_Poll_History(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		h.linken.Disconnect("group01", "node01")
+	}()
+
+	w := servePollForTest(h, "/poll?group=group01&history=true&epoch=1&fromVersion=1&timeout=1s")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t,
+		`{"epoch":1,"version":1,"nextSeq":4,"events":[{"version":1,"type":"node_zombie","node":"node01"}]}`,
+		strings.TrimSpace(w.Body.String()))
+
+	w = servePollForTest(h, "/poll?group=group01&history=true&epoch=1&fromSeq=4&timeout=20ms")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "", w.Body.String())
+}
+
+func TestWebsocketHandler_Events_History(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	server := httptest.NewServer(h.Events())
+	defer server.Close()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	resp, err := http.Get(server.URL + "?group=group01&history=true")
+	assert.Equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, `id: 1-3
+event: history
+data: {"epoch":1,"version":1,"nextSeq":3,"snapshot":{"version":1,"epoch":1,"nodes":["node01"],"partitions":[{"status":1,"owner":"node01","nextOwner":"","modVersion":1}]}}
+`, readEventForTest(t, reader))
+
+	h.linken.Disconnect("group01", "node01")
+
+	assert.Equal(t, `id: 1-4
+event: history
+data: {"epoch":1,"version":1,"nextSeq":4,"events":[{"version":1,"type":"node_zombie","node":"node01"}]}
+`, readEventForTest(t, reader))
+}
+
+func TestWebsocketHandler_Events_History_Last_Event_ID(t *testing.T) {
+	h := NewWebsocketHandler()
+	defer h.Shutdown()
+
+	server := httptest.NewServer(h.Events())
+	defer server.Close()
+
+	err := h.linken.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	h.linken.Disconnect("group01", "node01")
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"?group=group01&history=true", nil)
+	assert.Equal(t, nil, err)
+	req.Header.Set("Last-Event-ID", "1-3")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, `id: 1-4
+event: history
+data: {"epoch":1,"version":1,"nextSeq":4,"events":[{"version":1,"type":"node_zombie","node":"node01"}]}
+`, readEventForTest(t, reader))
+}
+
+func TestEventsHistoryCursor_Invalid_Last_Event_ID(t *testing.T) {
+	for _, id := range []string{"1", "1-x", "x-1", "1-2-3"} {
+		r := httptest.NewRequest(http.MethodGet, "/events?group=group01&history=true", nil)
+		r.Header.Set("Last-Event-ID", id)
+
+		_, err := eventsHistoryCursor(r)
+		assert.Equal(t, errors.New("invalid 'Last-Event-ID' header"), err, id)
+	}
+}
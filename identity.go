@@ -0,0 +1,60 @@
+package linken
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+// NodeIdentity is the identity of a node derived from its verified client certificate
+type NodeIdentity struct {
+	NodeName string
+	Groups   []string // allowed groups, empty means all groups are allowed
+}
+
+// NodeIdentityFunc derives the node identity from a verified peer certificate
+type NodeIdentityFunc func(cert *x509.Certificate) (NodeIdentity, error)
+
+// CertificateNodeIdentity uses Subject.CommonName as node name and Subject.OrganizationalUnit as allowed groups
+func CertificateNodeIdentity(cert *x509.Certificate) (NodeIdentity, error) {
+	if len(cert.Subject.CommonName) == 0 {
+		return NodeIdentity{}, errors.New("certificate 'CN' field must not be empty")
+	}
+	return NodeIdentity{
+		NodeName: cert.Subject.CommonName,
+		Groups:   cert.Subject.OrganizationalUnit,
+	}, nil
+}
+
+func peerNodeIdentity(state *tls.ConnectionState, identityFn NodeIdentityFunc) (*NodeIdentity, error) {
+	if identityFn == nil {
+		return nil, nil
+	}
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, errors.New("missing verified client certificate")
+	}
+
+	identity, err := identityFn(state.VerifiedChains[0][0])
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func validateJoinIdentity(join *ServerJoinCommand, identity *NodeIdentity) error {
+	if identity == nil {
+		return nil
+	}
+	if join.NodeName != identity.NodeName {
+		return errors.New("'nodeName' field not matched certificate identity")
+	}
+	if len(identity.Groups) == 0 {
+		return nil
+	}
+	for _, g := range identity.Groups {
+		if g == join.GroupName {
+			return nil
+		}
+	}
+	return errors.New("'groupName' field not allowed by certificate identity")
+}
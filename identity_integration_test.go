@@ -0,0 +1,144 @@
+package linken
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testCertAuthority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCertAuthority() *testCertAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCertAuthority{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCertAuthority) issue(serial int64, subject pkix.Name, usage x509.ExtKeyUsage) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		panic(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func newTestTLSServer(ca *testCertAuthority, handler *WebsocketHandler) (*httptest.Server, string) {
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(2, pkix.Name{CommonName: "server"}, x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	return server, "wss" + strings.TrimPrefix(server.URL, "https")
+}
+
+func TestWebsocketClient_Mutual_TLS(t *testing.T) {
+	ca := newTestCertAuthority()
+	handler := NewWebsocketHandler(WithNodeIdentity(CertificateNodeIdentity))
+	server, url := newTestTLSServer(ca, handler)
+	defer server.Close()
+	defer handler.Shutdown()
+
+	clientCert := ca.issue(3, pkix.Name{
+		CommonName:         "node01",
+		OrganizationalUnit: []string{"group01"},
+	}, x509.ExtKeyUsageClientAuth)
+
+	var mut sync.Mutex
+	var listenedNodes []string
+
+	newClient := func(groupName string, nodeName string) *WebsocketClient {
+		return NewWebsocketClient(url, groupName, nodeName, 3,
+			WithClientTLSConfig(&tls.Config{
+				RootCAs:      ca.pool,
+				Certificates: []tls.Certificate{clientCert},
+			}),
+			WithClientNodeListener(func(nodes []string) {
+				mut.Lock()
+				listenedNodes = nodes
+				mut.Unlock()
+			}),
+		)
+	}
+
+	impersonate := newClient("group01", "node02")
+	otherGroup := newClient("group02", "node01")
+	client := newClient("group01", "node01")
+
+	var wg sync.WaitGroup
+	for _, c := range []*WebsocketClient{impersonate, otherGroup, client} {
+		wg.Add(1)
+		go func(c *WebsocketClient) {
+			defer wg.Done()
+			c.Run()
+		}(c)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []string{"node01"}, listenedNodes)
+	mut.Unlock()
+
+	handler.linken.mut.RLock()
+	assert.Equal(t, 1, len(handler.linken.groups))
+	handler.linken.mut.RUnlock()
+
+	impersonate.Shutdown()
+	otherGroup.Shutdown()
+	client.Shutdown()
+	wg.Wait()
+}
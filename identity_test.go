@@ -0,0 +1,73 @@
+package linken
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCertificateNodeIdentity(t *testing.T) {
+	identity, err := CertificateNodeIdentity(&x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "node01",
+			OrganizationalUnit: []string{"group01", "group02"},
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NodeIdentity{
+		NodeName: "node01",
+		Groups:   []string{"group01", "group02"},
+	}, identity)
+
+	_, err = CertificateNodeIdentity(&x509.Certificate{})
+	assert.Equal(t, errors.New("certificate 'CN' field must not be empty"), err)
+}
+
+func TestValidateJoinIdentity(t *testing.T) {
+	table := []struct {
+		name     string
+		join     ServerJoinCommand
+		identity *NodeIdentity
+		err      error
+	}{
+		{
+			name: "without-identity",
+			join: ServerJoinCommand{GroupName: "group01", NodeName: "node01"},
+			err:  nil,
+		},
+		{
+			name:     "node-not-matched",
+			join:     ServerJoinCommand{GroupName: "group01", NodeName: "node02"},
+			identity: &NodeIdentity{NodeName: "node01"},
+			err:      errors.New("'nodeName' field not matched certificate identity"),
+		},
+		{
+			name:     "ok-all-groups",
+			join:     ServerJoinCommand{GroupName: "group01", NodeName: "node01"},
+			identity: &NodeIdentity{NodeName: "node01"},
+			err:      nil,
+		},
+		{
+			name:     "group-not-allowed",
+			join:     ServerJoinCommand{GroupName: "group03", NodeName: "node01"},
+			identity: &NodeIdentity{NodeName: "node01", Groups: []string{"group01", "group02"}},
+			err:      errors.New("'groupName' field not allowed by certificate identity"),
+		},
+		{
+			name:     "ok-group-allowed",
+			join:     ServerJoinCommand{GroupName: "group02", NodeName: "node01"},
+			identity: &NodeIdentity{NodeName: "node01", Groups: []string{"group01", "group02"}},
+			err:      nil,
+		},
+	}
+
+	for _, e := range table {
+		t.Run(e.name, func(t *testing.T) {
+			join := e.join
+			err := validateJoinIdentity(&join, e.identity)
+			assert.Equal(t, e.err, err)
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package linken
 
 import (
+	"context"
 	"errors"
+	"go.uber.org/zap"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,9 +15,17 @@ var ErrInvalidPartitionCount = errors.New("number of partitions not matched")
 
 // GroupData ...
 type GroupData struct {
-	Version    GroupVersion    `json:"version"`
+	Version GroupVersion `json:"version"`
+
+	// Epoch identifies the lifetime of the group, see HistoryCursor
+	Epoch GroupEpoch `json:"epoch,omitempty"`
+
 	Nodes      []string        `json:"nodes"`
 	Partitions []PartitionInfo `json:"partitions"`
+
+	// Replicas[i] are the standby nodes of the i-th partition in the order of promotion, see WithReplicaCount.
+	// Empty if no partition has replicas. Changing the replicas does not change ModVersion of the partitions
+	Replicas [][]string `json:"replicas,omitempty"`
 }
 
 // NotifyActionType ...
@@ -37,21 +49,65 @@ type NotifyPartitionData struct {
 type WatchRequest struct {
 	FromVersion  GroupVersion
 	ResponseChan chan<- GroupData
+
+	// Filter limits the changes waking up the watch, only used after the first response (FromVersion > 0)
+	Filter WatchFilter
+}
+
+// SessionID identifies a single connection of a node
+type SessionID uint64
+
+// NodeSession ...
+type NodeSession struct {
+	ID SessionID
+
+	// Terminated is closed when the session is superseded by a newer session of the same node
+	Terminated <-chan struct{}
 }
 
 // Linken ...
 type Linken struct {
 	options linkenOptions
 
+	lastSessionID uint64 // accessed atomically
+	lastEpoch     uint64 // accessed atomically
+
 	mut    sync.RWMutex
 	groups map[string]*linkenGroup
 }
 
+type nodeSession struct {
+	id         SessionID
+	terminated chan struct{}
+}
+
 type linkenGroup struct {
 	mut      sync.Mutex
 	count    int
 	state    *groupState
 	waitList []chan<- GroupData
+	sessions map[string]nodeSession
+
+	filteredWatches []filteredWatch
+	changes         groupChanges
+	history         groupHistory
+	historyWatches  []chan<- struct{}
+
+	// events of the operation being run, only collected while collecting = true
+	collecting bool
+	opEvents   []GroupEvent
+
+	observedQueue []observedOperation
+	dispatching   bool
+
+	timerFactory      groupTimerFactoryImpl
+	transitionTimeout time.Duration
+	partitionTimers   []partitionTimer
+}
+
+type partitionTimer struct {
+	modVersion GroupVersion
+	timer      groupTimer
 }
 
 // New ...
@@ -83,21 +139,38 @@ func (l *Linken) getGroup(
 	groupName string, inputHandler func(g *linkenGroup) error,
 	initFn func() *linkenGroup,
 ) error {
-	needDelete, err := l.getGroupReturningNeedDelete(groupName, inputHandler, initFn)
-	if needDelete {
+	result, err := l.getGroupReturningNeedDelete(groupName, inputHandler, initFn)
+	if result.needDispatch {
+		l.dispatchObservedOperations(groupName, result.group)
+		// the group was kept while dispatching
+		result.needDelete = true
+	}
+	if result.needDelete {
 		l.tryToDeleteGroup(groupName)
 	}
 	return err
 }
 
+type getGroupResult struct {
+	group      *linkenGroup
+	needDelete bool
+
+	// the caller is responsible for dispatching the observed operations of the group
+	needDispatch bool
+}
+
 func (l *Linken) getGroupReturningNeedDelete(
 	groupName string, inputHandler func(g *linkenGroup) error,
 	initFn func() *linkenGroup,
-) (bool, error) {
-	handler := func(g *linkenGroup) (bool, error) {
+) (getGroupResult, error) {
+	handler := func(g *linkenGroup) (getGroupResult, error) {
 		defer g.mut.Unlock()
 		err := inputHandler(g)
-		return g.needDelete(), err
+		return getGroupResult{
+			group:        g,
+			needDispatch: g.startDispatching(),
+			needDelete:   g.needDelete(),
+		}, err
 	}
 
 	l.mut.RLock()
@@ -121,16 +194,76 @@ func (l *Linken) getGroupReturningNeedDelete(
 	return handler(group)
 }
 
+func newLinkenGroup() *linkenGroup {
+	return &linkenGroup{
+		sessions: map[string]nodeSession{},
+		changes:  newGroupChanges(),
+	}
+}
+
 func (l *Linken) initLinkenGroup(g *linkenGroup, groupName string, count int, prevState *GroupData) {
 	g.count = count
-	g.state = newGroupStateOptions(count, groupTimerFactoryImpl{
+	g.timerFactory = groupTimerFactoryImpl{
 		groupName: groupName,
 		root:      l,
-	}, prevState, l.options)
+	}
+	g.state = newGroupStateOptions(count, g.timerFactory, prevState, l.options)
+	g.state.epoch = l.nextEpoch(prevState)
+	g.history = newGroupHistory(l.options.historySize)
+	g.history.published(g.state.version)
+	g.changes.record(g.state.toGroupData())
+
+	g.transitionTimeout = l.options.groupTransitionTimeout(groupName)
+	g.partitionTimers = make([]partitionTimer, count)
+	g.updatePartitionTimers()
+}
+
+// nextEpoch returns an epoch greater than the epochs of the previous groups,
+// and greater than the epoch of the previous state, so a group recovered after restarting has a new epoch
+func (l *Linken) nextEpoch(prevState *GroupData) GroupEpoch {
+	for {
+		last := atomic.LoadUint64(&l.lastEpoch)
+		next := last + 1
+		if prevState != nil && uint64(prevState.Epoch) >= next && prevState.Epoch < math.MaxUint64 {
+			next = uint64(prevState.Epoch) + 1
+		}
+		if atomic.CompareAndSwapUint64(&l.lastEpoch, last, next) {
+			return GroupEpoch(next)
+		}
+	}
 }
 
 // Join ...
 func (l *Linken) Join(groupName string, nodeName string, count int, prevState *GroupData) error {
+	return l.join(groupName, nodeName, count, prevState, func(g *linkenGroup) {})
+}
+
+// JoinSession joins the node like Join, but also binds the node to a new session.
+// The previous session of the same node is terminated, and the calls
+// LeaveSession, DisconnectSession and NotifySession of the previous session are ignored
+func (l *Linken) JoinSession(
+	groupName string, nodeName string, count int, prevState *GroupData,
+) (NodeSession, error) {
+	sess := nodeSession{
+		id:         SessionID(atomic.AddUint64(&l.lastSessionID, 1)),
+		terminated: make(chan struct{}),
+	}
+	err := l.join(groupName, nodeName, count, prevState, func(g *linkenGroup) {
+		g.replaceSession(nodeName, sess)
+	})
+	if err != nil {
+		return NodeSession{}, err
+	}
+	return NodeSession{
+		ID:         sess.id,
+		Terminated: sess.terminated,
+	}, nil
+}
+
+func (l *Linken) join(
+	groupName string, nodeName string, count int, prevState *GroupData,
+	joinedFn func(g *linkenGroup),
+) error {
 	return l.getGroup(groupName, func(g *linkenGroup) error {
 		needResponseWatches := false
 		if g.state == nil {
@@ -139,9 +272,16 @@ func (l *Linken) Join(groupName string, nodeName string, count int, prevState *G
 				needResponseWatches = true
 			}
 		}
-		return g.nodeJoin(nodeName, count, needResponseWatches)
+		return l.runOperation(g, groupName, nodeName, nodeName, AuditActionJoin, func() error {
+			err := g.nodeJoin(nodeName, count, needResponseWatches)
+			if err != nil {
+				return err
+			}
+			joinedFn(g)
+			return nil
+		})
 	}, func() *linkenGroup {
-		g := &linkenGroup{}
+		g := newLinkenGroup()
 		l.initLinkenGroup(g, groupName, count, prevState)
 		return g
 	})
@@ -155,50 +295,287 @@ func (l *Linken) getGroupWithoutInit(groupName string, fn func(g *linkenGroup))
 		}
 		fn(g)
 		return nil
-	}, func() *linkenGroup {
-		return &linkenGroup{}
-	})
+	}, newLinkenGroup)
 }
 
 // Leave ...
 func (l *Linken) Leave(groupName string, nodeName string) {
 	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
-		g.nodeLeave(nodeName)
+		l.auditNode(g, groupName, nodeName, AuditActionLeave, func() {
+			g.nodeLeave(nodeName)
+		})
 	})
 }
 
 // Disconnect ...
 func (l *Linken) Disconnect(groupName string, nodeName string) {
 	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
-		g.nodeDisconnect(nodeName)
+		l.auditNode(g, groupName, nodeName, AuditActionDisconnect, func() {
+			g.nodeDisconnect(nodeName)
+		})
 	})
 }
 
 // Notify ...
 func (l *Linken) Notify(groupName string, owner string, notifyList []NotifyPartitionData) {
 	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
-		g.notifyPartitions(owner, notifyList)
+		l.auditNode(g, groupName, owner, AuditActionNotify, func() {
+			g.notifyPartitions(owner, notifyList)
+		})
+	})
+}
+
+// LeaveSession is Leave, but is ignored if sess is not the current session of the node
+func (l *Linken) LeaveSession(groupName string, nodeName string, sess SessionID) {
+	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
+		if g.isCurrentSession(nodeName, sess) {
+			l.auditNode(g, groupName, nodeName, AuditActionLeave, func() {
+				g.nodeLeave(nodeName)
+			})
+		}
+	})
+}
+
+// DisconnectSession is Disconnect, but is ignored if sess is not the current session of the node
+func (l *Linken) DisconnectSession(groupName string, nodeName string, sess SessionID) {
+	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
+		if g.isCurrentSession(nodeName, sess) {
+			l.auditNode(g, groupName, nodeName, AuditActionDisconnect, func() {
+				g.nodeDisconnect(nodeName)
+			})
+		}
+	})
+}
+
+// NotifySession is Notify, but is ignored if sess is not the current session of the node
+func (l *Linken) NotifySession(
+	groupName string, owner string, sess SessionID, notifyList []NotifyPartitionData,
+) {
+	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
+		if g.isCurrentSession(owner, sess) {
+			l.auditNode(g, groupName, owner, AuditActionNotify, func() {
+				g.notifyPartitions(owner, notifyList)
+			})
+		}
 	})
 }
 
 func (l *Linken) nodeTimerExpired(groupName string, nodeName string) {
 	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
-		g.nodeExpired(nodeName)
+		_ = l.runOperation(g, groupName, AuditActorSystem, nodeName, AuditActionExpire, func() error {
+			g.nodeExpired(nodeName)
+			return nil
+		})
 	})
 }
 
+func (l *Linken) partitionTimerExpired(groupName string, id PartitionID, modVersion GroupVersion) {
+	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
+		if int(id) >= len(g.state.partitions) {
+			return
+		}
+		prev := g.state.partitions[id]
+
+		_ = l.runOperation(g, groupName, AuditActorSystem, prev.Owner, AuditActionTimeout, func() error {
+			if g.partitionTimeout(id, modVersion) {
+				l.options.logger.Warn("Partition transition timed out",
+					zap.String("group", groupName), zap.Uint32("partition", uint32(id)),
+					zap.String("owner", prev.Owner), zap.Int("status", int(prev.Status)),
+				)
+			}
+			return nil
+		})
+	})
+}
+
+func (l *Linken) auditNode(g *linkenGroup, groupName string, nodeName string, action AuditAction, fn func()) {
+	_ = l.runOperation(g, groupName, nodeName, nodeName, action, func() error {
+		fn()
+		return nil
+	})
+}
+
+// runOperation runs fn, records its changes to the audit sink and queues them for the observers.
+// Nothing is recorded if fn returns an error
+func (l *Linken) runOperation(
+	g *linkenGroup, groupName string, actor string, nodeName string,
+	action AuditAction, fn func() error,
+) error {
+	sink := l.options.auditSink
+	if sink == nil && len(l.options.observers) == 0 {
+		return fn()
+	}
+
+	before := g.state.version
+	g.collecting = true
+	g.opEvents = nil
+	err := fn()
+	g.collecting = false
+
+	events := g.opEvents
+	g.opEvents = nil
+	if err != nil {
+		return err
+	}
+
+	if sink != nil {
+		recordErr := sink.Record(AuditEntry{
+			Time:          l.options.clock.Now(),
+			Group:         groupName,
+			Actor:         actor,
+			Action:        action,
+			Node:          nodeName,
+			BeforeVersion: before,
+			AfterVersion:  g.state.version,
+			Changes:       events,
+		})
+		if recordErr != nil {
+			l.options.logger.Error("Error while record audit entry", zap.Error(recordErr))
+		}
+	}
+
+	if len(l.options.observers) > 0 && len(events) > 0 {
+		g.observedQueue = append(g.observedQueue, observedOperation{action: action, events: events})
+	}
+	return nil
+}
+
 // Watch ...
 func (l *Linken) Watch(groupName string, req WatchRequest) {
 	_ = l.getGroup(groupName, func(g *linkenGroup) error {
+		if !req.Filter.isEmpty() && req.FromVersion > 0 {
+			g.watchFiltered(req)
+			return nil
+		}
 		if g.state == nil || g.state.version < req.FromVersion {
 			g.waitList = append(g.waitList, req.ResponseChan)
 			return nil
 		}
 		req.ResponseChan <- g.state.toGroupData()
 		return nil
-	}, func() *linkenGroup {
-		return &linkenGroup{}
+	}, newLinkenGroup)
+}
+
+// History returns the events of the group after the cursor, or the snapshot of the current state
+// if some of those events are not kept in the history anymore or the cursor is of another epoch.
+// The zero cursor always returns the snapshot, see WatchHistory for receiving the events when they happen
+func (l *Linken) History(groupName string, cursor HistoryCursor) GroupHistory {
+	var result GroupHistory
+	l.getGroupWithoutInit(groupName, func(g *linkenGroup) {
+		result, _ = g.historySince(cursor)
 	})
+	return result
+}
+
+// WatchHistory returns a stream of the history of the group after the cursor, each GroupHistory in the stream
+// contains either the events after the previous one, or a snapshot when the events are not available anymore.
+// Like WatchContext, a slow receiver never blocks the group.
+// The stream is closed and the watch is removed after ctx is cancelled
+func (l *Linken) WatchHistory(ctx context.Context, groupName string, cursor HistoryCursor) <-chan GroupHistory {
+	result := make(chan GroupHistory)
+
+	go func() {
+		defer close(result)
+
+		// signaled at most once per registration, so the group never blocks on it
+		wake := make(chan struct{}, 1)
+		for {
+			var history GroupHistory
+			found := false
+			_ = l.getGroup(groupName, func(g *linkenGroup) error {
+				if g.state != nil {
+					history, found = g.historySince(cursor)
+				}
+				if !found {
+					g.historyWatches = append(g.historyWatches, wake)
+				}
+				return nil
+			}, newLinkenGroup)
+
+			if !found {
+				select {
+				case <-wake:
+					continue
+				case <-ctx.Done():
+					l.removeHistoryWatch(groupName, wake)
+					return
+				}
+			}
+
+			select {
+			case result <- history:
+			case <-ctx.Done():
+				return
+			}
+			cursor = history.Next()
+		}
+	}()
+
+	return result
+}
+
+func (l *Linken) removeHistoryWatch(groupName string, wake chan<- struct{}) {
+	_ = l.getGroup(groupName, func(g *linkenGroup) error {
+		remaining := g.historyWatches[:0]
+		for _, ch := range g.historyWatches {
+			if ch != wake {
+				remaining = append(remaining, ch)
+			}
+		}
+		for i := len(remaining); i < len(g.historyWatches); i++ {
+			g.historyWatches[i] = nil
+		}
+		g.historyWatches = remaining
+		return nil
+	}, newLinkenGroup)
+}
+
+// WatchContext returns a stream of group data, starting from the version >= fromVersion,
+// each data in the stream has a greater version than the previous one.
+// Unlike Watch, a slow receiver never blocks the group, it will only receive the latest data when ready.
+// The stream is closed and the watch is removed after ctx is cancelled
+func (l *Linken) WatchContext(ctx context.Context, groupName string, fromVersion GroupVersion) <-chan GroupData {
+	return l.WatchFilteredContext(ctx, groupName, fromVersion, WatchFilter{})
+}
+
+// WatchFilteredContext is WatchContext, but after the first data, only changes matching the filter are sent
+func (l *Linken) WatchFilteredContext(
+	ctx context.Context, groupName string, fromVersion GroupVersion, filter WatchFilter,
+) <-chan GroupData {
+	result := make(chan GroupData)
+
+	go func() {
+		defer close(result)
+
+		// at most one data is sent to ch before it is received, so the group never blocks on ch
+		ch := make(chan GroupData, 1)
+		currentFilter := WatchFilter{}
+		for {
+			l.Watch(groupName, WatchRequest{
+				FromVersion:  fromVersion,
+				ResponseChan: ch,
+				Filter:       currentFilter,
+			})
+
+			var data GroupData
+			select {
+			case data = <-ch:
+			case <-ctx.Done():
+				l.RemoveWatch(groupName, ch)
+				return
+			}
+
+			select {
+			case result <- data:
+			case <-ctx.Done():
+				return
+			}
+			fromVersion = data.Version + 1
+			currentFilter = filter
+		}
+	}()
+
+	return result
 }
 
 func removeWaitListEntry(waitList []chan<- GroupData, ch chan<- GroupData) []chan<- GroupData {
@@ -219,10 +596,9 @@ func removeWaitListEntry(waitList []chan<- GroupData, ch chan<- GroupData) []cha
 func (l *Linken) RemoveWatch(groupName string, ch chan<- GroupData) {
 	_ = l.getGroup(groupName, func(g *linkenGroup) error {
 		g.waitList = removeWaitListEntry(g.waitList, ch)
+		g.filteredWatches = removeFilteredWatch(g.filteredWatches, ch)
 		return nil
-	}, func() *linkenGroup {
-		return &linkenGroup{}
-	})
+	}, newLinkenGroup)
 }
 
 //revive:disable-next-line:flag-parameter
@@ -231,6 +607,10 @@ func (g *linkenGroup) nodeJoin(name string, count int, needResponseWatches bool)
 		return ErrInvalidPartitionCount
 	}
 
+	if prev, existed := g.state.nodes[name]; existed && prev.status == nodeStatusZombie {
+		g.recordEvent(GroupEvent{Version: g.state.version, Type: GroupEventTypeNodeJoined, Node: name})
+	}
+
 	changed := g.state.nodeJoin(name)
 	if changed {
 		g.state.version++
@@ -242,19 +622,37 @@ func (g *linkenGroup) nodeJoin(name string, count int, needResponseWatches bool)
 }
 
 func (g *linkenGroup) nodeDisconnect(name string) {
+	if prev, existed := g.state.nodes[name]; existed && prev.status == nodeStatusAlive {
+		g.recordEvent(GroupEvent{Version: g.state.version, Type: GroupEventTypeNodeZombie, Node: name})
+	}
 	g.state.nodeDisconnect(name)
 }
 
 func (g *linkenGroup) nodeLeave(name string) {
+	delete(g.sessions, name)
 	changed := g.state.nodeLeave(name)
 	g.groupChanged(changed)
 }
 
 func (g *linkenGroup) nodeExpired(name string) {
+	delete(g.sessions, name)
 	changed := g.state.nodeExpired(name)
 	g.groupChanged(changed)
 }
 
+func (g *linkenGroup) replaceSession(name string, sess nodeSession) {
+	prev, existed := g.sessions[name]
+	if existed {
+		close(prev.terminated)
+	}
+	g.sessions[name] = sess
+}
+
+func (g *linkenGroup) isCurrentSession(name string, sess SessionID) bool {
+	current, existed := g.sessions[name]
+	return existed && current.id == sess
+}
+
 func (g *linkenGroup) notifyPartitions(owner string, notifyList []NotifyPartitionData) {
 	resultChanged := false
 	for _, notify := range notifyList {
@@ -269,8 +667,104 @@ func (g *linkenGroup) notifyPartitions(owner string, notifyList []NotifyPartitio
 	g.groupChanged(resultChanged)
 }
 
+func (g *linkenGroup) partitionTimeout(id PartitionID, modVersion GroupVersion) bool {
+	prev := g.state.partitions[id]
+	if !g.state.transitionTimeout(id, modVersion) {
+		return false
+	}
+
+	g.recordEvent(GroupEvent{
+		Version: g.state.version + 1,
+		Type:    GroupEventTypePartitionTimeout,
+		Partition: &PartitionTransition{
+			ID:  id,
+			Old: prev,
+			New: g.state.partitions[id],
+		},
+	})
+	g.groupChanged(true)
+	return true
+}
+
+// updatePartitionTimers starts a timer for each partition newly moved to Starting or Stopping,
+// and stops the timers of the partitions not waiting anymore
+func (g *linkenGroup) updatePartitionTimers() {
+	if g.transitionTimeout <= 0 {
+		return
+	}
+
+	for i, p := range g.state.partitions {
+		t := &g.partitionTimers[i]
+		waiting := p.Status == PartitionStatusStarting || p.Status == PartitionStatusStopping
+
+		if t.timer != nil && (!waiting || t.modVersion != p.ModVersion) {
+			t.timer.stop()
+			*t = partitionTimer{}
+		}
+		if waiting && t.timer == nil {
+			*t = partitionTimer{
+				modVersion: p.ModVersion,
+				timer:      g.timerFactory.newPartitionTimer(PartitionID(i), p.ModVersion, g.transitionTimeout),
+			}
+		}
+	}
+}
+
+func (g *linkenGroup) recordEvent(e GroupEvent) {
+	g.history.append(e)
+	if g.collecting {
+		g.opEvents = append(g.opEvents, e)
+	}
+
+	for i, ch := range g.historyWatches {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+		g.historyWatches[i] = nil
+	}
+	g.historyWatches = g.historyWatches[:0]
+}
+
+// historySince returns found = false if there is no event after the cursor
+func (g *linkenGroup) historySince(cursor HistoryCursor) (history GroupHistory, found bool) {
+	history = GroupHistory{
+		Epoch:   g.state.epoch,
+		Version: g.state.version,
+		NextSeq: g.history.nextSeq,
+	}
+	events, ok := g.history.sinceCursor(g.state.epoch, cursor)
+	if !ok {
+		data := g.state.toGroupData()
+		history.Snapshot = &data
+		return history, true
+	}
+	history.Events = events
+	return history, len(events) > 0
+}
+
+func (g *linkenGroup) watchFiltered(req WatchRequest) {
+	if g.state != nil && g.changes.matched(req.Filter, req.FromVersion) {
+		req.ResponseChan <- g.state.toGroupData()
+		return
+	}
+	g.filteredWatches = append(g.filteredWatches, filteredWatch{
+		fromVersion: req.FromVersion,
+		filter:      req.Filter,
+		ch:          req.ResponseChan,
+	})
+}
+
 func (g *linkenGroup) pushResponseToWatchClients() {
+	g.updatePartitionTimers()
+
 	data := g.state.toGroupData()
+	for _, e := range diffGroupEvents(g.changes.published, data) {
+		g.recordEvent(e)
+	}
+	g.history.published(data.Version)
+	g.changes.record(data)
+
 	for _, ch := range g.waitList {
 		ch <- data
 	}
@@ -278,6 +772,19 @@ func (g *linkenGroup) pushResponseToWatchClients() {
 		g.waitList[i] = nil
 	}
 	g.waitList = g.waitList[:0]
+
+	remaining := g.filteredWatches[:0]
+	for _, w := range g.filteredWatches {
+		if g.changes.matched(w.filter, w.fromVersion) {
+			w.ch <- data
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	for i := len(remaining); i < len(g.filteredWatches); i++ {
+		g.filteredWatches[i] = filteredWatch{}
+	}
+	g.filteredWatches = remaining
 }
 
 //revive:disable-next-line:flag-parameter
@@ -289,11 +796,12 @@ func (g *linkenGroup) groupChanged(changed bool) {
 }
 
 func (g *linkenGroup) needDelete() bool {
-	return (g.state == nil || len(g.state.nodes) == 0) && len(g.waitList) == 0
+	return (g.state == nil || len(g.state.nodes) == 0) && len(g.waitList) == 0 && len(g.filteredWatches) == 0 &&
+		len(g.historyWatches) == 0 && !g.dispatching
 }
 
 type groupTimerImpl struct {
-	timer *time.Timer
+	timer ClockTimer
 }
 
 var _ groupTimer = groupTimerImpl{}
@@ -311,8 +819,16 @@ var _ groupTimerFactory = groupTimerFactoryImpl{}
 
 func (f groupTimerFactoryImpl) newTimer(name string, d time.Duration) groupTimer {
 	return groupTimerImpl{
-		timer: time.AfterFunc(d, func() {
+		timer: f.root.options.clock.AfterFunc(d, func() {
 			f.root.nodeTimerExpired(f.groupName, name)
 		}),
 	}
 }
+
+func (f groupTimerFactoryImpl) newPartitionTimer(id PartitionID, modVersion GroupVersion, d time.Duration) groupTimer {
+	return groupTimerImpl{
+		timer: f.root.options.clock.AfterFunc(d, func() {
+			f.root.partitionTimerExpired(f.groupName, id, modVersion)
+		}),
+	}
+}
@@ -1,6 +1,7 @@
 package linken
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
@@ -43,6 +44,7 @@ func TestLinken_Watch_After_Changed(t *testing.T) {
 
 	assert.Equal(t, GroupData{
 		Version: 2,
+		Epoch:   1,
 		Nodes:   []string{"node01", "node02"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
@@ -67,6 +69,7 @@ func TestLinken_Watch_Before_Changed(t *testing.T) {
 	d := getGroupDataChan(ch)
 	assert.Equal(t, GroupData{
 		Version: 1,
+		Epoch:   1,
 		Nodes:   []string{"node01"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
@@ -120,6 +123,7 @@ func TestLinken_Join_With_Prev_State(t *testing.T) {
 	d := getGroupDataChan(ch)
 	assert.Equal(t, GroupData{
 		Version: 21,
+		Epoch:   1,
 		Nodes:   []string{"node01", "node02", "node03"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 18},
@@ -152,6 +156,7 @@ func TestLinken_Join_With_Prev_State_When_Alreay_Watched(t *testing.T) {
 	d := getGroupDataChan(ch)
 	assert.Equal(t, GroupData{
 		Version: 21,
+		Epoch:   1,
 		Nodes:   []string{"node01", "node02", "node03"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 18},
@@ -173,6 +178,7 @@ func TestLinken_Leave_Normal(t *testing.T) {
 
 	assert.Equal(t, GroupData{
 		Version: 3,
+		Epoch:   1,
 		Nodes:   []string{"node01"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
@@ -198,6 +204,7 @@ func TestLinken_Leave_Not_Joined(t *testing.T) {
 	d := getGroupDataChan(ch)
 	assert.Equal(t, GroupData{
 		Version: 1,
+		Epoch:   1,
 		Nodes:   []string{"node01"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
@@ -226,6 +233,7 @@ func TestLinken_Disconnect_And_Then_Expired(t *testing.T) {
 	d := getGroupDataChan(ch)
 	assert.Equal(t, GroupData{
 		Version: 2,
+		Epoch:   1,
 		Nodes:   []string{},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusInit, Owner: "", ModVersion: 2},
@@ -273,6 +281,7 @@ func TestLinken_NotifyRunning(t *testing.T) {
 
 	assert.Equal(t, GroupData{
 		Version: 2,
+		Epoch:   1,
 		Nodes:   []string{"node01"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
@@ -299,6 +308,7 @@ func TestLinken_NotifyStopped(t *testing.T) {
 
 	assert.Equal(t, GroupData{
 		Version: 3,
+		Epoch:   1,
 		Nodes:   []string{"node01", "node02"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
@@ -313,6 +323,7 @@ func TestLinken_NotifyStopped(t *testing.T) {
 
 	assert.Equal(t, GroupData{
 		Version: 4,
+		Epoch:   1,
 		Nodes:   []string{"node01", "node02"},
 		Partitions: []PartitionInfo{
 			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
@@ -439,3 +450,499 @@ func TestRemoveWatchListEntry(t *testing.T) {
 		assert.Equal(t, []chan<- GroupData{a, b, c, d}, result)
 	})
 }
+
+func TestLinken_JoinSession_Supersede_Previous_Session(t *testing.T) {
+	l := New(WithNodeExpiredDuration(10 * time.Millisecond))
+
+	sess1, err := l.JoinSession("group01", "node01", 3, nil)
+	assert.Equal(t, nil, err)
+
+	sess2, err := l.JoinSession("group01", "node01", 3, nil)
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, sess1.ID, sess2.ID)
+
+	select {
+	case <-sess1.Terminated:
+	default:
+		t.Fatal("previous session must be terminated")
+	}
+
+	select {
+	case <-sess2.Terminated:
+		t.Fatal("current session must not be terminated")
+	default:
+	}
+
+	expected := GroupData{
+		Version: 1,
+		Epoch:   1,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		},
+	}
+
+	// calls of the previous session are ignored
+	l.NotifySession("group01", "node01", sess1.ID, []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 0, LastVersion: 1},
+	})
+	l.DisconnectSession("group01", "node01", sess1.ID)
+	l.LeaveSession("group01", "node01", sess1.ID)
+
+	time.Sleep(15 * time.Millisecond)
+	assert.Equal(t, expected, getCurrentGroupData(l, "group01"))
+
+	l.NotifySession("group01", "node01", sess2.ID, []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 0, LastVersion: 1},
+	})
+	l.LeaveSession("group01", "node01", sess2.ID)
+
+	l.mut.RLock()
+	assert.Equal(t, 0, len(l.groups))
+	l.mut.RUnlock()
+}
+
+func TestLinken_JoinSession_ErrInvalidPartitionCount(t *testing.T) {
+	l := New()
+
+	_, err := l.JoinSession("group01", "node01", 3, nil)
+	assert.Equal(t, nil, err)
+
+	sess, err := l.JoinSession("group01", "node01", 4, nil)
+	assert.Equal(t, ErrInvalidPartitionCount, err)
+	assert.Equal(t, NodeSession{}, sess)
+}
+
+func TestLinken_WatchContext(t *testing.T) {
+	l := New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := l.WatchContext(ctx, "group01", 0)
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, GroupData{
+		Version: 1,
+		Epoch:   1,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		},
+	}, <-stream)
+
+	// not receiving from the stream must not block the group
+	err = l.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+	err = l.Join("group01", "node03", 1, nil)
+	assert.Equal(t, nil, err)
+	l.Leave("group01", "node02")
+
+	// the receiver may or may not see the intermediate versions
+	data := <-stream
+	if data.Version < 4 {
+		data = <-stream
+	}
+	assert.Equal(t, GroupData{
+		Version: 4,
+		Epoch:   1,
+		Nodes:   []string{"node01", "node03"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		},
+	}, data)
+
+	cancel()
+
+	_, ok := <-stream
+	assert.Equal(t, false, ok)
+
+	l.Leave("group01", "node01")
+	l.Leave("group01", "node03")
+
+	l.mut.RLock()
+	assert.Equal(t, 0, len(l.groups))
+	l.mut.RUnlock()
+}
+
+func TestLinken_WatchContext_Cancel_Before_Changed(t *testing.T) {
+	l := New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := l.WatchContext(ctx, "group01", 0)
+
+	cancel()
+
+	_, ok := <-stream
+	assert.Equal(t, false, ok)
+
+	l.mut.RLock()
+	assert.Equal(t, 0, len(l.groups))
+	l.mut.RUnlock()
+}
+
+func TestLinken_Watch_With_Filter(t *testing.T) {
+	l := New()
+
+	err := l.Join("group01", "node01", 3, nil)
+	assert.Equal(t, nil, err)
+
+	membershipCh := make(chan GroupData, 1)
+	l.Watch("group01", WatchRequest{
+		FromVersion:  2,
+		ResponseChan: membershipCh,
+		Filter:       WatchFilter{Membership: true},
+	})
+
+	partitionCh := make(chan GroupData, 1)
+	l.Watch("group01", WatchRequest{
+		FromVersion:  2,
+		ResponseChan: partitionCh,
+		Filter:       WatchFilter{Partitions: []PartitionID{1}},
+	})
+
+	l.Notify("group01", "node01", []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 0, LastVersion: 1},
+	})
+
+	assert.Equal(t, GroupData{}, getGroupDataChan(membershipCh))
+	assert.Equal(t, GroupData{}, getGroupDataChan(partitionCh))
+
+	l.Notify("group01", "node01", []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 1, LastVersion: 1},
+	})
+
+	assert.Equal(t, GroupData{}, getGroupDataChan(membershipCh))
+	assert.Equal(t, GroupVersion(3), getGroupDataChan(partitionCh).Version)
+
+	err = l.Join("group01", "node02", 3, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, GroupVersion(4), getGroupDataChan(membershipCh).Version)
+
+	// already changed before watching
+	ownerCh := make(chan GroupData, 1)
+	l.Watch("group01", WatchRequest{
+		FromVersion:  4,
+		ResponseChan: ownerCh,
+		Filter:       WatchFilter{Owner: "node02"},
+	})
+	assert.Equal(t, GroupVersion(4), getGroupDataChan(ownerCh).Version)
+
+	l.RemoveWatch("group01", membershipCh)
+	l.RemoveWatch("group01", partitionCh)
+	l.RemoveWatch("group01", ownerCh)
+
+	l.Leave("group01", "node01")
+	l.Leave("group01", "node02")
+
+	l.mut.RLock()
+	assert.Equal(t, 0, len(l.groups))
+	l.mut.RUnlock()
+}
+
+func TestLinken_WatchFilteredContext(t *testing.T) {
+	l := New()
+
+	err := l.Join("group01", "node01", 3, nil)
+	assert.Equal(t, nil, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := l.WatchFilteredContext(ctx, "group01", 1, WatchFilter{Membership: true})
+	assert.Equal(t, GroupVersion(1), (<-stream).Version)
+
+	l.Notify("group01", "node01", []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 0, LastVersion: 1},
+	})
+	err = l.Join("group01", "node02", 3, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []string{"node01", "node02"}, (<-stream).Nodes)
+}
+
+func TestLinken_History(t *testing.T) {
+	l := New(WithHistorySize(4))
+
+	assert.Equal(t, GroupHistory{}, l.History("group01", HistoryCursor{Epoch: 1, Seq: 1}))
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, GroupHistory{
+		Epoch:   1,
+		Version: 1,
+		NextSeq: 3,
+		Events: []GroupEvent{
+			{Version: 1, Type: GroupEventTypeNodeJoined, Node: "node01"},
+			{
+				Version: 1,
+				Type:    GroupEventTypePartitionChanged,
+				Partition: &PartitionTransition{
+					New: PartitionInfo{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+				},
+			},
+		},
+	}, l.History("group01", HistoryCursor{Epoch: 1, Seq: 1}))
+
+	err = l.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, GroupHistory{
+		Epoch:   1,
+		Version: 2,
+		NextSeq: 4,
+		Events: []GroupEvent{
+			{Version: 2, Type: GroupEventTypeNodeJoined, Node: "node02"},
+		},
+	}, l.History("group01", HistoryCursor{Epoch: 1, Seq: 3}))
+
+	// the zombie and reconnect events do not increase the version, but are replayed from the next sequence
+	l.Disconnect("group01", "node02")
+	err = l.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, GroupHistory{
+		Epoch:   1,
+		Version: 2,
+		NextSeq: 6,
+		Events: []GroupEvent{
+			{Version: 2, Type: GroupEventTypeNodeZombie, Node: "node02"},
+			{Version: 2, Type: GroupEventTypeNodeJoined, Node: "node02"},
+		},
+	}, l.History("group01", HistoryCursor{Epoch: 1, Seq: 4}))
+
+	snapshot := GroupHistory{
+		Epoch:   1,
+		Version: 2,
+		NextSeq: 6,
+		Snapshot: &GroupData{
+			Version: 2,
+			Epoch:   1,
+			Nodes:   []string{"node01", "node02"},
+			Partitions: []PartitionInfo{
+				{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+			},
+		},
+	}
+
+	// truncated history
+	assert.Equal(t, snapshot, l.History("group01", HistoryCursor{Epoch: 1, Seq: 1}))
+
+	// zero cursor
+	assert.Equal(t, snapshot, l.History("group01", HistoryCursor{}))
+
+	// cursor of another epoch
+	assert.Equal(t, snapshot, l.History("group01", HistoryCursor{Epoch: 2, Seq: 4}))
+}
+
+func TestLinken_History_Since_Group_Data(t *testing.T) {
+	l := New(WithHistorySize(4))
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	d := getCurrentGroupData(l, "group01")
+	assert.Equal(t, HistoryCursor{Epoch: 1, Version: 1}, d.HistoryCursor())
+
+	l.Disconnect("group01", "node01")
+	err = l.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, GroupHistory{
+		Epoch:   1,
+		Version: 2,
+		NextSeq: 5,
+		Events: []GroupEvent{
+			{Version: 1, Type: GroupEventTypeNodeZombie, Node: "node01"},
+			{Version: 2, Type: GroupEventTypeNodeJoined, Node: "node02"},
+		},
+	}, l.History("group01", d.HistoryCursor()))
+
+	// version of the events already dropped
+	l.Leave("group01", "node02")
+	l.Leave("group01", "node01")
+	err = l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	history := l.History("group01", d.HistoryCursor())
+	assert.Equal(t, GroupVersion(1), history.Version)
+	assert.Equal(t, GroupEpoch(2), history.Epoch)
+	assert.NotNil(t, history.Snapshot)
+}
+
+func TestLinken_Epoch_Of_Recreated_Group(t *testing.T) {
+	l := New()
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GroupEpoch(1), getCurrentGroupData(l, "group01").Epoch)
+
+	l.Leave("group01", "node01")
+	err = l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GroupEpoch(2), getCurrentGroupData(l, "group01").Epoch)
+
+	// recovered from the state of a previous server
+	err = l.Join("group02", "node01", 1, &GroupData{
+		Version: 5,
+		Epoch:   10,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 4},
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GroupEpoch(11), getCurrentGroupData(l, "group02").Epoch)
+
+	err = l.Join("group03", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GroupEpoch(12), getCurrentGroupData(l, "group03").Epoch)
+}
+
+func TestLinken_Watch_History(t *testing.T) {
+	l := New(WithHistorySize(4))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := l.WatchHistory(ctx, "group01", HistoryCursor{})
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+
+	history := <-stream
+	assert.Equal(t, GroupHistory{
+		Epoch:   1,
+		Version: 1,
+		NextSeq: 3,
+		Snapshot: &GroupData{
+			Version: 1,
+			Epoch:   1,
+			Nodes:   []string{"node01"},
+			Partitions: []PartitionInfo{
+				{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+			},
+		},
+	}, history)
+
+	// the events not increasing the version are also sent
+	l.Disconnect("group01", "node01")
+
+	assert.Equal(t, GroupHistory{
+		Epoch:   1,
+		Version: 1,
+		NextSeq: 4,
+		Events: []GroupEvent{
+			{Version: 1, Type: GroupEventTypeNodeZombie, Node: "node01"},
+		},
+	}, <-stream)
+
+	cancel()
+	_, ok := <-stream
+	assert.Equal(t, false, ok)
+
+	// the watch does not keep the group
+	l.Leave("group01", "node01")
+	assert.Equal(t, GroupData{}, getCurrentGroupData(l, "group01"))
+}
+
+func TestLinken_Watch_History_Snapshot_When_Truncated(t *testing.T) {
+	l := New(WithHistorySize(2))
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	d := getCurrentGroupData(l, "group01")
+
+	l.Disconnect("group01", "node01")
+	err = l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	l.Disconnect("group01", "node01")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	history := <-l.WatchHistory(ctx, "group01", d.HistoryCursor())
+	assert.Equal(t, EventSeq(6), history.NextSeq)
+	assert.Equal(t, []GroupEvent(nil), history.Events)
+	assert.Equal(t, &GroupData{
+		Version: 1,
+		Epoch:   1,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		},
+	}, history.Snapshot)
+}
+
+func TestLinken_Transition_Timeout(t *testing.T) {
+	c := NewFakeClock(time.Now())
+	o := &recordObserver{}
+	l := New(WithClock(c), WithObserver(o), WithTransitionTimeout(10*time.Second))
+
+	err := l.Join("group01", "node01", 2, nil)
+	assert.Equal(t, nil, err)
+	l.Notify("group01", "node01", []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 0, LastVersion: 1},
+	})
+	err = l.Join("group01", "node02", 2, nil)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, GroupData{
+		Version: 3,
+		Epoch:   1,
+		Nodes:   []string{"node01", "node02"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 3},
+		},
+	}, getCurrentGroupData(l, "group01"))
+	assert.Equal(t, 1, c.PendingTimers())
+
+	c.Advance(10 * time.Second)
+
+	assert.Equal(t, GroupData{
+		Version: 4,
+		Epoch:   1,
+		Nodes:   []string{"node01", "node02"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 4},
+		},
+	}, getCurrentGroupData(l, "group01"))
+
+	l.Notify("group01", "node02", []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 1, LastVersion: 4},
+	})
+	assert.Equal(t, 0, c.PendingTimers())
+
+	calls := o.getCalls()
+	assert.Equal(t, []string{
+		"timeout group01 4 1 3->1 node01->node02",
+		"partition group01 4 1 3->1 node01->node02",
+		"partition group01 5 1 1->2 node02->node02",
+	}, calls[len(calls)-3:])
+}
+
+func TestLinken_Group_Transition_Timeout(t *testing.T) {
+	c := NewFakeClock(time.Now())
+	l := New(
+		WithClock(c),
+		WithTransitionTimeout(10*time.Second),
+		WithGroupTransitionTimeout("group02", 0),
+	)
+
+	err := l.Join("group01", "node01", 2, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, c.PendingTimers())
+
+	err = l.Join("group02", "node01", 2, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, c.PendingTimers())
+
+	l.Leave("group01", "node01")
+	assert.Equal(t, 0, c.PendingTimers())
+}
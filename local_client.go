@@ -0,0 +1,124 @@
+package linken
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+)
+
+// ErrSessionSuperseded is returned from LocalClient.RunContext if the node joined again with another client
+var ErrSessionSuperseded = errors.New("node joined again with another client")
+
+// Client is implemented by both WebsocketClient and LocalClient
+type Client interface {
+	Run()
+	RunContext(ctx context.Context) error
+	Shutdown()
+	Close() error
+	Snapshot() ClientSnapshot
+}
+
+// LocalClient is a client calling a *Linken in the same process instead of connecting to a server
+type LocalClient struct {
+	clientCore
+
+	linken    *Linken
+	groupName string
+
+	rootCtx context.Context
+	cancel  func()
+
+	lifecycle clientLifecycle
+}
+
+var _ Client = &LocalClient{}
+var _ Client = &WebsocketClient{}
+
+// NewLocalClient ...
+func NewLocalClient(
+	l *Linken, groupName string, nodeName string, count int,
+	options ...ClientOption,
+) *LocalClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &LocalClient{
+		clientCore: newClientCore(nodeName, count, options...),
+
+		linken:    l,
+		groupName: groupName,
+
+		rootCtx: ctx,
+		cancel:  cancel,
+	}
+}
+
+// Run is RunContext without a context and ignoring the returned error
+func (c *LocalClient) Run() {
+	_ = c.RunContext(context.Background())
+}
+
+// RunContext joins the group and handles the group states until ctx is done, Shutdown or Close is called.
+// Returns ctx.Err() if ctx is done, the join error, ErrSessionSuperseded, or nil if stopped by Shutdown or Close.
+// The client can not be run again after RunContext returned, a concurrent call returns nil immediately
+func (c *LocalClient) RunContext(ctx context.Context) error {
+	if !c.lifecycle.begin() {
+		return nil
+	}
+	defer c.lifecycle.end()
+
+	return runWithContext(ctx, c.rootCtx, c.cancel, c.run)
+}
+
+func (c *LocalClient) run() error {
+	logger := c.options.logger
+
+	c.loadPrevState()
+	sess, err := c.linken.JoinSession(c.groupName, c.nodeName, c.count, c.prevState)
+	if err != nil {
+		logger.Error("Error while Join", zap.Error(err))
+		c.options.errorListener(err)
+		return err
+	}
+	c.prevState = nil
+	defer c.status.disconnected()
+
+	fromVersion := GroupVersion(0)
+	ch := make(chan GroupData, 1)
+
+	for {
+		c.linken.Watch(c.groupName, WatchRequest{
+			FromVersion:  fromVersion,
+			ResponseChan: ch,
+		})
+
+		select {
+		case data := <-ch:
+			notifyList := c.handleGroupData(data)
+			if len(notifyList) > 0 {
+				c.linken.NotifySession(c.groupName, c.nodeName, sess.ID, notifyList)
+			}
+			fromVersion = data.Version + 1
+
+		case <-sess.Terminated:
+			c.linken.RemoveWatch(c.groupName, ch)
+			return ErrSessionSuperseded
+
+		case <-c.rootCtx.Done():
+			c.linken.RemoveWatch(c.groupName, ch)
+			c.linken.LeaveSession(c.groupName, c.nodeName, sess.ID)
+			return nil
+		}
+	}
+}
+
+// Shutdown leaves the group without waiting, use Close to wait for it to stop
+func (c *LocalClient) Shutdown() {
+	c.cancel()
+}
+
+// Close leaves the group and waits for RunContext to return, no listener is called after Close returned
+func (c *LocalClient) Close() error {
+	c.cancel()
+	c.lifecycle.close()
+	return nil
+}
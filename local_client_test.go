@@ -0,0 +1,177 @@
+package linken
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalClient(t *testing.T) {
+	l := New()
+
+	var mut sync.Mutex
+	var listenedNodes []string
+	var updated []partitionUpdated
+
+	client := NewLocalClient(l, "group01", "node01", 3,
+		WithClientNodeListener(func(nodes []string) {
+			mut.Lock()
+			listenedNodes = nodes
+			mut.Unlock()
+		}),
+		WithClientPartitionListener(func(p PartitionID, owner string) {
+			mut.Lock()
+			updated = append(updated, partitionUpdated{id: p, owner: owner})
+			mut.Unlock()
+		}),
+	)
+	anotherClient := NewLocalClient(l, "group01", "node02", 3)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []string{"node01"}, listenedNodes)
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+		{id: 2, owner: "node01"},
+	}, updated)
+	mut.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		anotherClient.Run()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []string{"node01", "node02"}, listenedNodes)
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+		{id: 2, owner: "node01"},
+		{id: 2, owner: ""},
+		{id: 2, owner: "node02"},
+	}, updated)
+	mut.Unlock()
+
+	anotherClient.Shutdown()
+	client.Shutdown()
+	wg.Wait()
+
+	l.mut.RLock()
+	assert.Equal(t, 0, len(l.groups))
+	l.mut.RUnlock()
+}
+
+func TestLocalClient_Invalid_Partition_Count(t *testing.T) {
+	l := New()
+	err := l.Join("group01", "node01", 3, nil)
+	assert.Equal(t, nil, err)
+
+	var replied []error
+	client := NewLocalClient(l, "group01", "node02", 4,
+		WithClientErrorListener(func(err error) {
+			replied = append(replied, err)
+		}),
+	)
+	client.Run()
+
+	assert.Equal(t, []error{ErrInvalidPartitionCount}, replied)
+}
+
+func TestLocalClient_RunContext(t *testing.T) {
+	l := New()
+
+	err := l.Join("group01", "node02", 3, nil)
+	assert.Equal(t, nil, err)
+
+	client := NewLocalClient(l, "group01", "node01", 2)
+	assert.Equal(t, ErrInvalidPartitionCount, client.RunContext(context.Background()))
+
+	client = NewLocalClient(l, "group01", "node01", 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.RunContext(ctx)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, []string{"node01", "node02"}, getCurrentGroupData(l, "group01").Nodes)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-errCh)
+	assert.Equal(t, []string{"node02"}, getCurrentGroupData(l, "group01").Nodes)
+}
+
+func TestLocalClient_Close_Waits_For_Run(t *testing.T) {
+	l := New()
+	client := NewLocalClient(l, "group01", "node01", 3)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.RunContext(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, nil, client.Close())
+	select {
+	case err := <-errCh:
+		assert.Equal(t, nil, err)
+	default:
+		t.Fatal("RunContext must return before Close returned")
+	}
+	assert.Equal(t, GroupData{}, getCurrentGroupData(l, "group01"))
+}
+
+func TestLocalClient_Concurrent_RunContext(t *testing.T) {
+	l := New()
+	client := NewLocalClient(l, "group01", "node01", 3)
+
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errCh <- client.RunContext(context.Background())
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		assert.Equal(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("the second RunContext must return immediately")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, []string{"node01"}, getCurrentGroupData(l, "group01").Nodes)
+
+	assert.Equal(t, nil, client.Close())
+	assert.Equal(t, nil, <-errCh)
+	assert.Equal(t, GroupData{}, getCurrentGroupData(l, "group01"))
+}
+
+func TestLocalClient_Superseded(t *testing.T) {
+	l := New()
+	client := NewLocalClient(l, "group01", "node01", 3)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.RunContext(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	_, err := l.JoinSession("group01", "node01", 3, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ErrSessionSuperseded, <-errCh)
+}
@@ -0,0 +1,506 @@
+package linken
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrGroupAlreadyAdded is returned from MultiGroupClient.AddGroup if the group is already added
+	ErrGroupAlreadyAdded = errors.New("group already added")
+
+	// ErrMultiGroupNotSupported is returned from MultiGroupClient.RunContext if the server
+	// does not support CapabilityMultiGroup
+	ErrMultiGroupNotSupported = errors.New("server does not support multi group connections")
+)
+
+// MultiGroupClient joins multiple groups using a single connection, each group has its own listeners.
+// The first group is joined with the handshake, the others are joined after CapabilityMultiGroup negotiated.
+// WithClientSafeOwnership is not supported
+type MultiGroupClient struct {
+	transport ClientTransport
+	nodeName  string
+
+	optionList []ClientOption
+	options    clientOptions
+
+	rootCtx context.Context
+	cancel  func()
+
+	mut     sync.Mutex
+	groups  map[string]*multiGroupHandler
+	current *multiGroupClientConn
+	added   chan struct{}
+
+	joined   bool
+	joinedAt time.Time
+
+	lifecycle clientLifecycle
+}
+
+// multiGroupHandler handles the group states of a group of MultiGroupClient
+type multiGroupHandler struct {
+	clientCore
+	groupName string
+}
+
+// multiGroupClientConn is the connection finished the handshake, commands are written by a single goroutine
+type multiGroupClientConn struct {
+	ctx context.Context
+
+	mut    sync.Mutex
+	queue  []multiGroupCommand
+	signal chan struct{}
+}
+
+// multiGroupCommand is a command waiting to be written, status is the status of the group of a notify command
+type multiGroupCommand struct {
+	cmd    ServerCommand
+	status *clientStatus
+}
+
+func (c *multiGroupClientConn) send(cmd ServerCommand) {
+	c.sendWithStatus(cmd, nil)
+}
+
+// sendWithStatus never blocks, so it can be called with the lock of MultiGroupClient held.
+// The commands are written in the same order they are sent
+func (c *multiGroupClientConn) sendWithStatus(cmd ServerCommand, status *clientStatus) {
+	c.mut.Lock()
+	c.queue = append(c.queue, multiGroupCommand{cmd: cmd, status: status})
+	c.mut.Unlock()
+
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (c *multiGroupClientConn) takeCommands() []multiGroupCommand {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	queue := c.queue
+	c.queue = nil
+	return queue
+}
+
+// NewMultiGroupWebsocketClient ...
+func NewMultiGroupWebsocketClient(url string, nodeName string, options ...ClientOption) *MultiGroupClient {
+	c := NewMultiGroupClient(nil, nodeName, options...)
+	c.transport = NewWebsocketTransport(url, c.options.dialer)
+	return c
+}
+
+// NewMultiGroupClient creates a client joining multiple groups using the transport.
+// The options are the default options of every group added by AddGroup
+func NewMultiGroupClient(transport ClientTransport, nodeName string, options ...ClientOption) *MultiGroupClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &MultiGroupClient{
+		transport: transport,
+		nodeName:  nodeName,
+
+		optionList: options,
+		options:    computeClientOptions(options...),
+
+		rootCtx: ctx,
+		cancel:  cancel,
+
+		groups: map[string]*multiGroupHandler{},
+		added:  make(chan struct{}, 1),
+	}
+}
+
+// AddGroup joins the group with its own options (listeners, secret, state store, ...),
+// overriding the options of the client. Can be called before or while running
+func (c *MultiGroupClient) AddGroup(groupName string, count int, options ...ClientOption) error {
+	options = append(append([]ClientOption(nil), c.optionList...), options...)
+	h := &multiGroupHandler{
+		clientCore: newClientCore(c.nodeName, count, options...),
+		groupName:  groupName,
+	}
+	h.loadPrevState()
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if _, existed := c.groups[groupName]; existed {
+		return ErrGroupAlreadyAdded
+	}
+	c.groups[groupName] = h
+
+	if c.current != nil {
+		c.current.send(c.joinCommand(h))
+	}
+
+	select {
+	case c.added <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RemoveGroup leaves the group, no listener of the group is called after RemoveGroup returned,
+// except the calls already running
+func (c *MultiGroupClient) RemoveGroup(groupName string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	h, existed := c.groups[groupName]
+	if !existed {
+		return
+	}
+	delete(c.groups, groupName)
+	h.status.disconnected()
+
+	if c.current != nil {
+		c.current.send(ServerCommand{Type: ServerCommandTypeLeave, Group: groupName})
+	}
+}
+
+// Snapshot returns the current state of the group, false if the group is not added
+func (c *MultiGroupClient) Snapshot(groupName string) (ClientSnapshot, bool) {
+	h := c.getGroup(groupName)
+	if h == nil {
+		return ClientSnapshot{}, false
+	}
+	return h.Snapshot(), true
+}
+
+func (c *MultiGroupClient) getGroup(groupName string) *multiGroupHandler {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.groups[groupName]
+}
+
+// joinCommand is called with the lock held, the previous state is only sent in the first join
+func (c *MultiGroupClient) joinCommand(h *multiGroupHandler) ServerCommand {
+	cmd := ServerCommand{
+		Type: ServerCommandTypeJoin,
+		Join: &ServerJoinCommand{
+			GroupName:      h.groupName,
+			NodeName:       c.nodeName,
+			PartitionCount: h.count,
+			Secret:         h.options.secret,
+			PrevState:      h.prevState,
+		},
+	}
+	h.prevState = nil
+	return cmd
+}
+
+// Run is RunContext without a context and ignoring the returned error
+func (c *MultiGroupClient) Run() {
+	_ = c.RunContext(context.Background())
+}
+
+// RunContext connects to the server and handles the states of the added groups, reconnecting when the connection
+// is lost, until ctx is done, Shutdown or Close is called. A group replied a permanent error is removed,
+// except a rejected previous state, the group is joined again without it.
+// Returns ctx.Err() if ctx is done, ErrMultiGroupNotSupported, or nil if stopped by Shutdown or Close.
+// The client can not be run again after RunContext returned, a concurrent call returns nil immediately
+func (c *MultiGroupClient) RunContext(ctx context.Context) error {
+	if !c.lifecycle.begin() {
+		return nil
+	}
+	defer c.lifecycle.end()
+
+	return runWithContext(ctx, c.rootCtx, c.cancel, c.run)
+}
+
+func (c *MultiGroupClient) run() error {
+	clock := c.options.clock
+	policy := c.options.backoffPolicy()
+	retry := newBackoff(policy, clock.Now().UnixNano())
+
+	for {
+		if !c.waitForGroups() {
+			return nil
+		}
+
+		err := c.runInLoop()
+		if errors.Is(err, ErrMultiGroupNotSupported) {
+			return err
+		}
+		if c.rootCtx.Err() != nil {
+			return nil
+		}
+		if c.joined && clock.Now().Sub(c.joinedAt) >= policy.ResetAfter {
+			retry.reset()
+		}
+		c.options.connListener(ConnectionStateRetrying)
+		sleepContext(c.rootCtx, clock, retry.next())
+		if c.rootCtx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// waitForGroups returns false if stopped before any group added
+func (c *MultiGroupClient) waitForGroups() bool {
+	for {
+		c.mut.Lock()
+		count := len(c.groups)
+		c.mut.Unlock()
+
+		if count > 0 {
+			return true
+		}
+
+		select {
+		case <-c.rootCtx.Done():
+			return false
+		case <-c.added:
+		}
+	}
+}
+
+// firstGroup returns the join command of the group with the smallest name, for the handshake
+func (c *MultiGroupClient) firstGroup() (*multiGroupHandler, ServerCommand) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	names := make([]string, 0, len(c.groups))
+	for name := range c.groups {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, ServerCommand{}
+	}
+	sort.Strings(names)
+
+	h := c.groups[names[0]]
+	cmd := c.joinCommand(h)
+	cmd.Join.ProtocolVersions = c.options.protocolVersions
+	cmd.Join.Capabilities = c.capabilities()
+	return h, cmd
+}
+
+func (c *MultiGroupClient) capabilities() []Capability {
+	capabilities := append([]Capability(nil), c.options.capabilities...)
+	if !(protocolInfo{capabilities: capabilities}).hasCapability(CapabilityMultiGroup) {
+		capabilities = append(capabilities, CapabilityMultiGroup)
+	}
+	return capabilities
+}
+
+func (c *MultiGroupClient) runInLoop() error {
+	logger := c.options.logger
+	c.joined = false
+
+	conn, err := c.transport.Dial(c.rootCtx)
+	if err != nil {
+		logger.Error("Dial server failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	first, cmd := c.firstGroup()
+	if first == nil {
+		return nil
+	}
+
+	err = conn.WriteJSON(cmd)
+	if err != nil {
+		logger.Error("Error while WriteJSON", zap.Error(err))
+		return err
+	}
+
+	c.options.connListener(ConnectionStateConnected)
+	defer c.options.connListener(ConnectionStateDisconnected)
+
+	resp, err := c.handShake(conn, first)
+	if err != nil {
+		return err
+	}
+
+	c.joined = true
+	c.joinedAt = c.options.clock.Now()
+	c.options.connListener(ConnectionStateJoined)
+
+	ctx, cancel := context.WithCancel(c.rootCtx)
+	mc := &multiGroupClientConn{
+		ctx:    ctx,
+		signal: make(chan struct{}, 1),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+
+		c.sendCommands(mc, conn)
+	}()
+
+	c.handleResponse(mc, resp)
+	c.joinOtherGroups(mc, first)
+	c.receiveResponses(mc, conn)
+
+	c.mut.Lock()
+	c.current = nil
+	for _, h := range c.groups {
+		h.status.disconnected()
+	}
+	c.mut.Unlock()
+
+	cancel()
+	wg.Wait()
+	return nil
+}
+
+// handShake waits for the response of the first join and checks CapabilityMultiGroup negotiated
+func (c *MultiGroupClient) handShake(conn Conn, first *multiGroupHandler) (ServerResponse, error) {
+	logger := c.options.logger
+
+	var resp ServerResponse
+	err := conn.ReadJSON(&resp)
+	if err != nil {
+		logger.Error("Error while ReadJSON", zap.Error(err))
+		return ServerResponse{}, err
+	}
+
+	if resp.Error != nil {
+		c.handleGroupError(nil, first, resp.Error)
+		return ServerResponse{}, resp.Error
+	}
+
+	negotiated := protocolInfo{capabilities: resp.Capabilities}
+	if !negotiated.hasCapability(CapabilityMultiGroup) {
+		logger.Error("Server does not support multi group connections")
+		return ServerResponse{}, ErrMultiGroupNotSupported
+	}
+	return resp, nil
+}
+
+// joinOtherGroups joins the groups added before the connection can be used by AddGroup and RemoveGroup
+func (c *MultiGroupClient) joinOtherGroups(mc *multiGroupClientConn, first *multiGroupHandler) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.current = mc
+	if c.groups[first.groupName] != first {
+		mc.send(ServerCommand{Type: ServerCommandTypeLeave, Group: first.groupName})
+	}
+
+	for _, h := range c.groups {
+		if h != first {
+			mc.send(c.joinCommand(h))
+		}
+	}
+}
+
+func (c *MultiGroupClient) sendCommands(mc *multiGroupClientConn, conn Conn) {
+	logger := c.options.logger
+	defer closeConnGracefully(c.rootCtx, conn, logger)
+
+	for {
+		select {
+		case <-mc.ctx.Done():
+			return
+		case <-mc.signal:
+		}
+
+		for _, item := range mc.takeCommands() {
+			err := conn.WriteJSON(item.cmd)
+			if err != nil {
+				logger.Error("Error while WriteJSON", zap.Error(err))
+				return
+			}
+			if item.status != nil {
+				item.status.removePending(len(item.cmd.Notify))
+			}
+		}
+	}
+}
+
+func (c *MultiGroupClient) receiveResponses(mc *multiGroupClientConn, conn Conn) {
+	logger := c.options.logger
+
+	for {
+		var resp ServerResponse
+		err := conn.ReadJSON(&resp)
+		if err != nil {
+			if !errorIsCloseNormal(err) {
+				logger.Error("Error while ReadJSON", zap.Error(err))
+			}
+			return
+		}
+
+		c.handleResponse(mc, resp)
+	}
+}
+
+func (c *MultiGroupClient) handleResponse(mc *multiGroupClientConn, resp ServerResponse) {
+	h := c.getGroup(resp.Group)
+	if h == nil {
+		// the group is removed, the responses already sent by the server are ignored
+		return
+	}
+
+	if resp.Error != nil {
+		c.handleGroupError(mc, h, resp.Error)
+		return
+	}
+
+	notifyList := h.handleGroupData(resp.GroupData)
+	if len(notifyList) > 0 {
+		h.status.addPending(notifyList)
+		mc.sendWithStatus(ServerCommand{
+			Type:   ServerCommandTypeNotify,
+			Notify: notifyList,
+			Group:  h.groupName,
+		}, h.status)
+	}
+}
+
+// handleGroupError removes the group if the error is permanent, except a rejected previous state,
+// otherwise joins it again after the retry duration if the connection is still the same
+func (c *MultiGroupClient) handleGroupError(mc *multiGroupClientConn, h *multiGroupHandler, serverErr *ServerError) {
+	c.options.logger.Error("Server replied error",
+		zap.String("group", h.groupName), zap.Error(serverErr))
+	h.options.errorListener(serverErr)
+	h.status.disconnected()
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.groups[h.groupName] != h {
+		return
+	}
+	if serverErr.Permanent() && !h.clearRejectedPrevState(serverErr) {
+		delete(c.groups, h.groupName)
+		return
+	}
+	if mc == nil {
+		return
+	}
+
+	c.options.clock.AfterFunc(c.options.backoffPolicy().Initial, func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+
+		if c.current == mc && c.groups[h.groupName] == h {
+			mc.send(c.joinCommand(h))
+		}
+	})
+}
+
+// Shutdown leaves all the groups without waiting, use Close to wait for it to stop
+func (c *MultiGroupClient) Shutdown() {
+	c.cancel()
+}
+
+// Close leaves all the groups and waits for RunContext to return, no listener is called after Close returned
+func (c *MultiGroupClient) Close() error {
+	c.cancel()
+	c.lifecycle.close()
+	return nil
+}
@@ -0,0 +1,326 @@
+package linken
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func joinMultiGroupForTest(t *testing.T, conn *websocket.Conn, groupName string, nodeName string, count int) {
+	t.Helper()
+
+	err := conn.WriteJSON(ServerCommand{
+		Type: ServerCommandTypeJoin,
+		Join: &ServerJoinCommand{
+			GroupName:      groupName,
+			NodeName:       nodeName,
+			PartitionCount: count,
+
+			ProtocolVersions: []ProtocolVersion{ProtocolVersion1},
+			Capabilities:     []Capability{CapabilityMultiGroup},
+		},
+	})
+	assert.Equal(t, nil, err)
+
+	expected := `
+{
+  "group": "` + groupName + `",
+  "version": 1,
+  "epoch": 1,
+  "nodes": [
+    "` + nodeName + `"
+  ],
+  "partitions": [
+    {
+      "status": 1,
+      "owner": "` + nodeName + `",
+      "nextOwner": "",
+      "modVersion": 1
+    }
+  ],
+  "protocolVersion": 1,
+  "capabilities": [
+    "multi_group"
+  ]
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(connReadText(t, conn)))
+}
+
+func TestWebsocketHandler_Multi_Group_Join_And_Leave(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	conn := connectToServer()
+	defer func() { _ = conn.Close() }()
+
+	joinMultiGroupForTest(t, conn, "group01", "node01", 1)
+
+	connWriteText(t, conn, `
+{
+  "type": "join",
+  "join": {
+    "groupName": "group02",
+    "nodeName": "node01",
+    "partitionCount": 1
+  }
+}
+`)
+	expected := `
+{
+  "group": "group02",
+  "version": 1,
+  "epoch": 2,
+  "nodes": [
+    "node01"
+  ],
+  "partitions": [
+    {
+      "status": 1,
+      "owner": "node01",
+      "nextOwner": "",
+      "modVersion": 1
+    }
+  ]
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(connReadText(t, conn)))
+
+	// ANOTHER NODE JOINS GROUP02
+	conn2 := connectToServer()
+	defer func() { _ = conn2.Close() }()
+	joinNodeForTest(t, conn2, "group02", "node02", 1)
+
+	expected = `
+{
+  "group": "group02",
+  "version": 2,
+  "epoch": 2,
+  "nodes": [
+    "node01",
+    "node02"
+  ],
+  "partitions": [
+    {
+      "status": 1,
+      "owner": "node01",
+      "nextOwner": "",
+      "modVersion": 1
+    }
+  ]
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(connReadText(t, conn)))
+
+	// LEAVE GROUP01, the connection still receives the changes of group02
+	connWriteText(t, conn, `{"type": "leave", "group": "group01"}`)
+	time.Sleep(30 * time.Millisecond)
+
+	assert.Equal(t, GroupData{}, getCurrentGroupData(tc.handler.linken, "group01"))
+	assert.Equal(t, []string{"node01", "node02"}, getCurrentGroupData(tc.handler.linken, "group02").Nodes)
+
+	closeWebsocket(t, conn)
+}
+
+func TestWebsocketHandler_Multi_Group_Join_Error_Keeps_Connection(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	conn := connectToServer()
+	defer func() { _ = conn.Close() }()
+
+	joinMultiGroupForTest(t, conn, "group01", "node01", 1)
+
+	connWriteText(t, conn, `
+{
+  "type": "join",
+  "join": {
+    "groupName": "group01",
+    "nodeName": "node01",
+    "partitionCount": 1
+  }
+}
+`)
+	expected := `
+{
+  "group": "group01",
+  "error": {
+    "code": "invalid_command",
+    "message": "group already joined on this connection"
+  }
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(connReadText(t, conn)))
+
+	connWriteText(t, conn, `
+{
+  "type": "join",
+  "join": {
+    "groupName": "group02",
+    "nodeName": "",
+    "partitionCount": 1
+  }
+}
+`)
+	expected = `
+{
+  "group": "group02",
+  "error": {
+    "code": "invalid_command",
+    "message": "'nodeName' field must not be empty"
+  }
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(connReadText(t, conn)))
+
+	// SUPERSEDED BY A NEWER CONNECTION
+	conn2 := connectToServer()
+	defer func() { _ = conn2.Close() }()
+	joinNodeForTest(t, conn2, "group01", "node01", 1)
+
+	expected = `
+{
+  "group": "group01",
+  "error": {
+    "code": "session_superseded",
+    "message": "node joined again with another connection"
+  }
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(connReadText(t, conn)))
+
+	closeWebsocket(t, conn)
+	closeWebsocket(t, conn2)
+}
+
+func TestMultiGroupClient(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	var mut sync.Mutex
+	var updated1 []partitionUpdated
+	var updated2 []partitionUpdated
+
+	client := NewMultiGroupWebsocketClient("ws://localhost:8765/core", "node01",
+		WithClientLogger(tc.logger),
+	)
+
+	err := client.AddGroup("group01", 2, WithClientPartitionListener(func(p PartitionID, owner string) {
+		mut.Lock()
+		updated1 = append(updated1, partitionUpdated{id: p, owner: owner})
+		mut.Unlock()
+	}))
+	assert.Equal(t, nil, err)
+
+	err = client.AddGroup("group01", 2)
+	assert.Equal(t, ErrGroupAlreadyAdded, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+	}, updated1)
+	mut.Unlock()
+
+	// ADD GROUP WHILE RUNNING
+	err = client.AddGroup("group02", 1, WithClientPartitionListener(func(p PartitionID, owner string) {
+		mut.Lock()
+		updated2 = append(updated2, partitionUpdated{id: p, owner: owner})
+		mut.Unlock()
+	}))
+	assert.Equal(t, nil, err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+	}, updated2)
+	mut.Unlock()
+
+	// ANOTHER NODE JOINS GROUP01, the client notifies the stopped partition through the shared connection
+	another := NewWebsocketClient("ws://localhost:8765/core", "group01", "node02", 2,
+		WithClientLogger(tc.logger),
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		another.Run()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+		{id: 1, owner: ""},
+		{id: 1, owner: "node02"},
+	}, updated1)
+	mut.Unlock()
+
+	snapshot, ok := client.Snapshot("group01")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, snapshot.Connected)
+	assert.Equal(t, []string{"node01", "node02"}, snapshot.Nodes)
+	assert.Equal(t, 1, snapshot.RunningCount())
+	assert.Equal(t, 1, another.Snapshot().RunningCount())
+
+	// REMOVE GROUP
+	client.RemoveGroup("group02")
+	time.Sleep(50 * time.Millisecond)
+
+	_, ok = client.Snapshot("group02")
+	assert.Equal(t, false, ok)
+	assert.Equal(t, GroupData{}, getCurrentGroupData(tc.handler.linken, "group02"))
+
+	err = client.Close()
+	assert.Equal(t, nil, err)
+	another.Shutdown()
+	wg.Wait()
+}
+
+func TestMultiGroupClient_Server_Not_Supported(t *testing.T) {
+	tc := newTestCase(WithCapabilities())
+	defer tc.shutdown()
+
+	client := NewMultiGroupWebsocketClient("ws://localhost:8765/core", "node01",
+		WithClientLogger(tc.logger),
+	)
+	err := client.AddGroup("group01", 1)
+	assert.Equal(t, nil, err)
+
+	err = client.RunContext(context.Background())
+	assert.Equal(t, ErrMultiGroupNotSupported, err)
+}
+
+func TestMultiGroupClientConn_Send_Not_Blocked_By_Writer(t *testing.T) {
+	mc := &multiGroupClientConn{
+		ctx:    context.Background(),
+		signal: make(chan struct{}, 1),
+	}
+
+	// no goroutine is writing the commands
+	mc.send(ServerCommand{Type: ServerCommandTypeLeave, Group: "group01"})
+	mc.send(ServerCommand{Type: ServerCommandTypeLeave, Group: "group02"})
+
+	assert.Equal(t, 1, len(mc.signal))
+	assert.Equal(t, []multiGroupCommand{
+		{cmd: ServerCommand{Type: ServerCommandTypeLeave, Group: "group01"}},
+		{cmd: ServerCommand{Type: ServerCommandTypeLeave, Group: "group02"}},
+	}, mc.takeCommands())
+	assert.Equal(t, []multiGroupCommand(nil), mc.takeCommands())
+}
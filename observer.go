@@ -0,0 +1,103 @@
+package linken
+
+// Observer receives the events of the groups. The callbacks are called after the lock of the group is released,
+// events of the same group are delivered in order, one at a time
+type Observer interface {
+	// OnNodeJoin is called when a node joined the group, or reconnected while being a zombie
+	OnNodeJoin(group string, node string)
+	// OnNodeLeave is called when a node left the group
+	OnNodeLeave(group string, node string)
+	// OnNodeZombie is called when a node disconnected without leaving
+	OnNodeZombie(group string, node string)
+	// OnNodeExpired is called when a zombie node removed after the node expired duration
+	OnNodeExpired(group string, node string)
+	// OnPartitionTransition is called when a partition changed its status or owner
+	OnPartitionTransition(group string, version GroupVersion, transition PartitionTransition)
+	// OnPartitionTimeout is called when a partition stuck in Starting or Stopping is forced to move,
+	// before the OnPartitionTransition of the same change
+	OnPartitionTimeout(group string, version GroupVersion, transition PartitionTransition)
+}
+
+// NopObserver implements Observer with empty callbacks, for embedding
+type NopObserver struct {
+}
+
+var _ Observer = NopObserver{}
+
+// OnNodeJoin ...
+func (NopObserver) OnNodeJoin(string, string) {}
+
+// OnNodeLeave ...
+func (NopObserver) OnNodeLeave(string, string) {}
+
+// OnNodeZombie ...
+func (NopObserver) OnNodeZombie(string, string) {}
+
+// OnNodeExpired ...
+func (NopObserver) OnNodeExpired(string, string) {}
+
+// OnPartitionTransition ...
+func (NopObserver) OnPartitionTransition(string, GroupVersion, PartitionTransition) {}
+
+// OnPartitionTimeout ...
+func (NopObserver) OnPartitionTimeout(string, GroupVersion, PartitionTransition) {}
+
+// observedOperation is the events caused by a single operation, waiting to be delivered to the observers
+type observedOperation struct {
+	action AuditAction
+	events []GroupEvent
+}
+
+func notifyObserver(o Observer, groupName string, op observedOperation) {
+	for _, e := range op.events {
+		switch e.Type {
+		case GroupEventTypeNodeJoined:
+			o.OnNodeJoin(groupName, e.Node)
+		case GroupEventTypeNodeZombie:
+			o.OnNodeZombie(groupName, e.Node)
+		case GroupEventTypeNodeLeft:
+			if op.action == AuditActionExpire {
+				o.OnNodeExpired(groupName, e.Node)
+			} else {
+				o.OnNodeLeave(groupName, e.Node)
+			}
+		case GroupEventTypePartitionChanged:
+			o.OnPartitionTransition(groupName, e.Version, *e.Partition)
+		case GroupEventTypePartitionTimeout:
+			o.OnPartitionTimeout(groupName, e.Version, *e.Partition)
+		}
+	}
+}
+
+// dispatchObservedOperations delivers the queued operations of the group until the queue is empty,
+// only called by the caller that started the dispatching, so the operations are delivered in order
+func (l *Linken) dispatchObservedOperations(groupName string, g *linkenGroup) {
+	for {
+		g.mut.Lock()
+		queue := g.observedQueue
+		g.observedQueue = nil
+		if len(queue) == 0 {
+			g.dispatching = false
+		}
+		g.mut.Unlock()
+
+		if len(queue) == 0 {
+			return
+		}
+
+		for _, op := range queue {
+			for _, o := range l.options.observers {
+				notifyObserver(o, groupName, op)
+			}
+		}
+	}
+}
+
+// startDispatching returns true if the caller is responsible for dispatching the queued operations
+func (g *linkenGroup) startDispatching() bool {
+	if g.dispatching || len(g.observedQueue) == 0 {
+		return false
+	}
+	g.dispatching = true
+	return true
+}
@@ -0,0 +1,138 @@
+package linken
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordObserver struct {
+	NopObserver
+
+	mut   sync.Mutex
+	calls []string
+
+	// called inside OnNodeLeave, to check the group is not locked
+	onLeave func()
+}
+
+func (o *recordObserver) record(format string, args ...interface{}) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.calls = append(o.calls, fmt.Sprintf(format, args...))
+}
+
+func (o *recordObserver) getCalls() []string {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.calls
+}
+
+func (o *recordObserver) OnNodeJoin(group string, node string) {
+	o.record("join %s %s", group, node)
+}
+
+func (o *recordObserver) OnNodeLeave(group string, node string) {
+	if o.onLeave != nil {
+		o.onLeave()
+	}
+	o.record("leave %s %s", group, node)
+}
+
+func (o *recordObserver) OnNodeZombie(group string, node string) {
+	o.record("zombie %s %s", group, node)
+}
+
+func (o *recordObserver) OnNodeExpired(group string, node string) {
+	o.record("expired %s %s", group, node)
+}
+
+func (o *recordObserver) OnPartitionTransition(group string, version GroupVersion, t PartitionTransition) {
+	o.record("partition %s %d %d %d->%d %s->%s",
+		group, version, t.ID, t.Old.Status, t.New.Status, t.Old.Owner, t.New.Owner)
+}
+
+func (o *recordObserver) OnPartitionTimeout(group string, version GroupVersion, t PartitionTransition) {
+	o.record("timeout %s %d %d %d->%d %s->%s",
+		group, version, t.ID, t.Old.Status, t.New.Status, t.Old.Owner, t.New.Owner)
+}
+
+func TestLinken_Observer(t *testing.T) {
+	o := &recordObserver{}
+	c := NewFakeClock(time.Now())
+	l := New(WithObserver(o), WithClock(c), WithNodeExpiredDuration(30*time.Second))
+
+	err := l.Join("group01", "node01", 2, nil)
+	assert.Equal(t, nil, err)
+
+	l.Notify("group01", "node01", []NotifyPartitionData{
+		{Action: NotifyActionTypeRunning, Partition: 0, LastVersion: 1},
+		{Action: NotifyActionTypeRunning, Partition: 1, LastVersion: 1},
+	})
+
+	err = l.Join("group01", "node02", 2, nil)
+	assert.Equal(t, nil, err)
+
+	l.Notify("group01", "node01", []NotifyPartitionData{
+		{Action: NotifyActionTypeStopped, Partition: 1, LastVersion: 3},
+	})
+
+	l.Disconnect("group01", "node02")
+	c.Advance(30 * time.Second)
+
+	assert.Equal(t, []string{
+		"join group01 node01",
+		"partition group01 1 0 0->1 ->node01",
+		"partition group01 1 1 0->1 ->node01",
+		"partition group01 2 0 1->2 node01->node01",
+		"partition group01 2 1 1->2 node01->node01",
+		"join group01 node02",
+		"partition group01 3 1 2->3 node01->node01",
+		"partition group01 4 1 3->1 node01->node02",
+		"zombie group01 node02",
+		"expired group01 node02",
+		"partition group01 5 1 1->1 node02->node01",
+	}, o.getCalls())
+}
+
+func TestLinken_Observer_Called_Without_Group_Lock(t *testing.T) {
+	o := &recordObserver{}
+	l := New(WithObserver(o))
+
+	var data GroupData
+	o.onLeave = func() {
+		data = getCurrentGroupData(l, "group01")
+	}
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	err = l.Join("group01", "node02", 1, nil)
+	assert.Equal(t, nil, err)
+
+	l.Leave("group01", "node02")
+
+	assert.Equal(t, GroupVersion(3), data.Version)
+	assert.Equal(t, []string{"node01"}, data.Nodes)
+}
+
+func TestLinken_Observer_Delete_Group_After_Dispatched(t *testing.T) {
+	o := &recordObserver{}
+	l := New(WithObserver(o))
+
+	err := l.Join("group01", "node01", 1, nil)
+	assert.Equal(t, nil, err)
+	l.Leave("group01", "node01")
+
+	assert.Equal(t, []string{
+		"join group01 node01",
+		"partition group01 1 0 0->1 ->node01",
+		"leave group01 node01",
+		"partition group01 2 0 1->0 node01->",
+	}, o.getCalls())
+
+	l.mut.RLock()
+	assert.Equal(t, 0, len(l.groups))
+	l.mut.RUnlock()
+}
@@ -15,6 +15,17 @@ type linkenOptions struct {
 	nodeExpiredDuration time.Duration
 	logger              *zap.Logger
 	groupSecrets        map[string]GroupSecret
+	nodeIdentity        NodeIdentityFunc
+	protocolVersions    []ProtocolVersion
+	capabilities        []Capability
+	maxPollTimeout      time.Duration
+	historySize         int
+	auditSink           AuditSink
+	observers           []Observer
+	clock               Clock
+	transitionTimeout   time.Duration
+	groupTransitions    map[string]time.Duration
+	replicaCount        int
 }
 
 // Option ...
@@ -25,6 +36,12 @@ func computeLinkenOptions(options ...Option) linkenOptions {
 		nodeExpiredDuration: 30 * time.Second,
 		logger:              zap.NewNop(),
 		groupSecrets:        map[string]GroupSecret{},
+		groupTransitions:    map[string]time.Duration{},
+		protocolVersions:    defaultProtocolVersions,
+		capabilities:        defaultServerCapabilities,
+		maxPollTimeout:      30 * time.Second,
+		historySize:         1024,
+		clock:               SystemClock{},
 	}
 	for _, o := range options {
 		o(&result)
@@ -52,3 +69,91 @@ func WithGroupSecret(groupName string, secret GroupSecret) Option {
 		opts.groupSecrets[groupName] = secret
 	}
 }
+
+// WithNodeIdentity requires nodes to present a verified client certificate,
+// the join command is rejected if its node name or group is not matched the identity derived by fn
+func WithNodeIdentity(fn NodeIdentityFunc) Option {
+	return func(opts *linkenOptions) {
+		opts.nodeIdentity = fn
+	}
+}
+
+// WithProtocolVersions sets the protocol versions supported by the server
+func WithProtocolVersions(versions ...ProtocolVersion) Option {
+	return func(opts *linkenOptions) {
+		opts.protocolVersions = versions
+	}
+}
+
+// WithCapabilities sets the protocol capabilities enabled on the server, replacing the default CapabilityMultiGroup
+func WithCapabilities(capabilities ...Capability) Option {
+	return func(opts *linkenOptions) {
+		opts.capabilities = capabilities
+	}
+}
+
+// WithMaxPollTimeout sets the default and also the max timeout of the long-poll watch endpoint
+func WithMaxPollTimeout(d time.Duration) Option {
+	return func(opts *linkenOptions) {
+		opts.maxPollTimeout = d
+	}
+}
+
+// WithHistorySize sets the max number of events kept in the history of each group, 0 disables the history
+func WithHistorySize(size int) Option {
+	return func(opts *linkenOptions) {
+		opts.historySize = size
+	}
+}
+
+// WithAuditSink records every join, leave, disconnect, expiration and notify of the groups to sink
+func WithAuditSink(sink AuditSink) Option {
+	return func(opts *linkenOptions) {
+		opts.auditSink = sink
+	}
+}
+
+// WithObserver registers an observer receiving the events of all groups, can be used multiple times
+func WithObserver(o Observer) Option {
+	return func(opts *linkenOptions) {
+		opts.observers = append(opts.observers, o)
+	}
+}
+
+// WithClock sets the clock used for node expiration and audit entries, default is SystemClock
+func WithClock(clock Clock) Option {
+	return func(opts *linkenOptions) {
+		opts.clock = clock
+	}
+}
+
+// WithTransitionTimeout sets the max duration a partition can stay in Starting or Stopping for all groups,
+// after that the partition is forced to move. 0 (the default) disables the timeout
+func WithTransitionTimeout(d time.Duration) Option {
+	return func(opts *linkenOptions) {
+		opts.transitionTimeout = d
+	}
+}
+
+// WithGroupTransitionTimeout is WithTransitionTimeout for a single group, overrides the one of all groups
+func WithGroupTransitionTimeout(groupName string, d time.Duration) Option {
+	return func(opts *linkenOptions) {
+		opts.groupTransitions[groupName] = d
+	}
+}
+
+// WithReplicaCount assigns n standby nodes to each partition, other than its owner, rebalanced with the owners.
+// When the owner leaves or expires, the partition is started on its first alive replica. Default is 0
+func WithReplicaCount(n int) Option {
+	return func(opts *linkenOptions) {
+		opts.replicaCount = n
+	}
+}
+
+func (o linkenOptions) groupTransitionTimeout(groupName string) time.Duration {
+	d, ok := o.groupTransitions[groupName]
+	if ok {
+		return d
+	}
+	return o.transitionTimeout
+}
@@ -0,0 +1,84 @@
+package linken
+
+import (
+	"errors"
+)
+
+// ProtocolVersion is the version of the wire protocol between nodes and the server
+type ProtocolVersion int
+
+const (
+	// ProtocolVersion1 is the initial protocol, used when the join command does not contain any versions
+	ProtocolVersion1 ProtocolVersion = 1
+)
+
+// Capability is an optional feature of the wire protocol, enabled only if both sides support it
+type Capability string
+
+const (
+	// CapabilityMultiGroup lets a single connection join and leave multiple groups,
+	// the commands and responses after the first join are tagged with the group name
+	CapabilityMultiGroup Capability = "multi_group"
+)
+
+var defaultProtocolVersions = []ProtocolVersion{ProtocolVersion1}
+
+var defaultCapabilities []Capability
+
+var defaultServerCapabilities = []Capability{CapabilityMultiGroup}
+
+var errUnsupportedProtocolVersion = errors.New("no mutually supported protocol version")
+
+type protocolInfo struct {
+	version      ProtocolVersion
+	capabilities []Capability
+}
+
+func (p protocolInfo) hasCapability(c Capability) bool {
+	for _, e := range p.capabilities {
+		if e == c {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateProtocol chooses the highest mutually supported version and the common capabilities.
+// clientVersions empty means the client is a legacy client only supporting ProtocolVersion1
+func negotiateProtocol(
+	clientVersions []ProtocolVersion, clientCapabilities []Capability,
+	serverVersions []ProtocolVersion, serverCapabilities []Capability,
+) (protocolInfo, error) {
+	if len(clientVersions) == 0 {
+		clientVersions = []ProtocolVersion{ProtocolVersion1}
+	}
+
+	var chosen ProtocolVersion
+	for _, v := range clientVersions {
+		if v <= chosen {
+			continue
+		}
+		for _, serverVersion := range serverVersions {
+			if v == serverVersion {
+				chosen = v
+				break
+			}
+		}
+	}
+	if chosen == 0 {
+		return protocolInfo{}, errUnsupportedProtocolVersion
+	}
+
+	client := protocolInfo{capabilities: clientCapabilities}
+	var capabilities []Capability
+	for _, c := range serverCapabilities {
+		if client.hasCapability(c) {
+			capabilities = append(capabilities, c)
+		}
+	}
+
+	return protocolInfo{
+		version:      chosen,
+		capabilities: capabilities,
+	}, nil
+}
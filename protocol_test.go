@@ -0,0 +1,67 @@
+package linken
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	table := []struct {
+		name               string
+		clientVersions     []ProtocolVersion
+		clientCapabilities []Capability
+		serverVersions     []ProtocolVersion
+		serverCapabilities []Capability
+		result             protocolInfo
+		err                error
+	}{
+		{
+			name:           "legacy-client",
+			serverVersions: []ProtocolVersion{1, 2},
+			result:         protocolInfo{version: 1},
+		},
+		{
+			name:           "legacy-client-not-supported",
+			serverVersions: []ProtocolVersion{2},
+			err:            errUnsupportedProtocolVersion,
+		},
+		{
+			name:           "choose-highest-mutual",
+			clientVersions: []ProtocolVersion{1, 3, 2},
+			serverVersions: []ProtocolVersion{1, 2, 4},
+			result:         protocolInfo{version: 2},
+		},
+		{
+			name:           "no-mutual-version",
+			clientVersions: []ProtocolVersion{3},
+			serverVersions: []ProtocolVersion{1, 2},
+			err:            errUnsupportedProtocolVersion,
+		},
+		{
+			name:               "common-capabilities",
+			clientVersions:     []ProtocolVersion{1},
+			clientCapabilities: []Capability{"c", "a"},
+			serverVersions:     []ProtocolVersion{1},
+			serverCapabilities: []Capability{"a", "b", "c"},
+			result: protocolInfo{
+				version:      1,
+				capabilities: []Capability{"a", "c"},
+			},
+		},
+	}
+
+	for _, e := range table {
+		t.Run(e.name, func(t *testing.T) {
+			result, err := negotiateProtocol(e.clientVersions, e.clientCapabilities,
+				e.serverVersions, e.serverCapabilities)
+			assert.Equal(t, e.err, err)
+			assert.Equal(t, e.result, result)
+		})
+	}
+}
+
+func TestProtocolInfo_HasCapability(t *testing.T) {
+	p := protocolInfo{version: 1, capabilities: []Capability{"a", "b"}}
+	assert.Equal(t, true, p.hasCapability("b"))
+	assert.Equal(t, false, p.hasCapability("c"))
+}
@@ -0,0 +1,167 @@
+package linken
+
+import (
+	"sort"
+)
+
+// assignReplicas places WithReplicaCount replicas for each partition having an owner, on nodes other than
+// the owner and the next owner. The valid replicas are kept as long as their nodes are not overloaded,
+// the missing ones are placed on the nodes having the least replicas
+func (s *groupState) assignReplicas() {
+	nodes := make([]string, 0, len(s.nodes))
+	for name := range s.nodes {
+		nodes = append(nodes, name)
+	}
+	sort.Strings(nodes)
+
+	want := s.options.replicaCount
+	if want > len(nodes)-1 {
+		want = len(nodes) - 1
+	}
+	if want <= 0 {
+		for i := range s.replicas {
+			s.replicas[i] = nil
+		}
+		return
+	}
+
+	owned := 0
+	for _, p := range s.partitions {
+		if p.Status != PartitionStatusInit {
+			owned++
+		}
+	}
+	maxLoad := (owned*want + len(nodes) - 1) / len(nodes)
+
+	load := map[string]int{}
+	result := s.keepReplicas(want, maxLoad, load)
+
+	for i, p := range s.partitions {
+		if p.Status == PartitionStatusInit {
+			continue
+		}
+		for len(result[i]) < want {
+			replica := s.leastLoadedReplica(p, result[i], nodes, load)
+			if replica == "" {
+				break
+			}
+			result[i] = append(result[i], replica)
+			load[replica]++
+		}
+	}
+
+	s.replicas = result
+}
+
+// keepReplicas returns the current replicas still valid, up to maxLoad replicas on each node
+func (s *groupState) keepReplicas(want int, maxLoad int, load map[string]int) [][]string {
+	result := make([][]string, len(s.partitions))
+	for i, p := range s.partitions {
+		if p.Status == PartitionStatusInit {
+			continue
+		}
+		for _, replica := range s.replicas[i] {
+			if len(result[i]) >= want || load[replica] >= maxLoad || !s.canReplicate(p, result[i], replica) {
+				continue
+			}
+			result[i] = append(result[i], replica)
+			load[replica]++
+		}
+	}
+	return result
+}
+
+// canReplicate returns true if the node is in the group and not the owner, next owner or one of the replicas
+func (s *groupState) canReplicate(p PartitionInfo, replicas []string, node string) bool {
+	if _, existed := s.nodes[node]; !existed {
+		return false
+	}
+	if node == p.Owner || node == p.NextOwner {
+		return false
+	}
+	for _, r := range replicas {
+		if r == node {
+			return false
+		}
+	}
+	return true
+}
+
+// leastLoadedReplica returns the node can replicate the partition having the least replicas,
+// empty if there is no such node (a Stopping partition with a next owner excludes 2 nodes)
+func (s *groupState) leastLoadedReplica(
+	p PartitionInfo, replicas []string, nodes []string, load map[string]int,
+) string {
+	result := ""
+	for _, name := range nodes {
+		if !s.canReplicate(p, replicas, name) {
+			continue
+		}
+		if result == "" || load[name] < load[result] {
+			result = name
+		}
+	}
+	return result
+}
+
+func replicasEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// promoteReplica starts the partition on its first alive replica after its owner left,
+// or moves the partition to Init status to be reallocated if there is no such replica
+func (s *groupState) promoteReplica(id PartitionID) {
+	prev := s.replicas[id]
+	for i, replica := range prev {
+		info, existed := s.nodes[replica]
+		if !existed || info.status != nodeStatusAlive {
+			continue
+		}
+
+		s.partitions[id] = PartitionInfo{
+			Status:     PartitionStatusStarting,
+			Owner:      replica,
+			ModVersion: s.version + 1,
+		}
+		replicas := append([]string(nil), prev[:i]...)
+		s.replicas[id] = append(replicas, prev[i+1:]...)
+		return
+	}
+
+	s.partitions[id] = PartitionInfo{
+		Status:     PartitionStatusInit,
+		ModVersion: s.version + 1,
+	}
+	s.replicas[id] = nil
+}
+
+// cloneReplicas returns nil if no partition has replicas
+func (s *groupState) cloneReplicas() [][]string {
+	var result [][]string
+	for i, replicas := range s.replicas {
+		if len(replicas) == 0 {
+			continue
+		}
+		if result == nil {
+			result = make([][]string, len(s.replicas))
+		}
+		result[i] = append([]string(nil), replicas...)
+	}
+	return result
+}
+
+// replicasOf returns the replicas of the partition, nil if the data does not contain the replicas
+func replicasOf(data GroupData, id PartitionID) []string {
+	if int(id) >= len(data.Replicas) {
+		return nil
+	}
+	return data.Replicas[id]
+}
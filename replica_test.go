@@ -0,0 +1,187 @@
+package linken
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newGroupStateWithReplicas(count int, replicas int) *groupState {
+	factory := &groupTimerFactoryMock{
+		newTimerFunc: func(name string, d time.Duration) groupTimer {
+			return &groupTimerMock{stopFunc: func() {}}
+		},
+	}
+	return newGroupStateOptions(count, factory, nil, computeLinkenOptions(WithReplicaCount(replicas)))
+}
+
+func TestGroupState_Replicas_Not_On_Owners(t *testing.T) {
+	s := newGroupStateWithReplicas(3, 1)
+
+	s.nodeJoin("node01")
+	s.version++
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+	}, s.partitions)
+
+	s.nodeJoin("node02")
+	s.version++
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 2},
+	}, s.partitions)
+	assert.Equal(t, [][]string{{"node02"}, {"node02"}, nil}, s.replicas)
+
+	s.notifyStopped(2, "node01", 2)
+	s.version++
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 3},
+	}, s.partitions)
+	assert.Equal(t, [][]string{{"node02"}, {"node02"}, {"node01"}}, s.replicas)
+}
+
+func TestGroupState_Replicas_Rebalanced_With_Owners(t *testing.T) {
+	s := newGroupStateWithReplicas(3, 1)
+
+	s.nodeJoin("node01")
+	s.version++
+	s.nodeJoin("node02")
+	s.version++
+	s.notifyStopped(2, "node01", 2)
+	s.version++
+
+	s.nodeJoin("node03")
+	s.version++
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node03", ModVersion: 4},
+		{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 3},
+	}, s.partitions)
+	assert.Equal(t, [][]string{{"node02"}, {"node02"}, {"node01"}}, s.replicas)
+
+	s.notifyStopped(1, "node01", 4)
+	s.version++
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node03", ModVersion: 5},
+		{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 3},
+	}, s.partitions)
+	assert.Equal(t, [][]string{{"node02"}, {"node01"}, {"node01"}}, s.replicas)
+}
+
+func TestGroupState_Replica_Promoted_When_Owner_Leave(t *testing.T) {
+	s := newGroupStateWithReplicas(3, 1)
+
+	s.nodeJoin("node01")
+	s.version++
+	s.nodeJoin("node02")
+	s.version++
+	s.notifyStopped(2, "node01", 2)
+	s.version++
+	s.nodeJoin("node03")
+	s.version++
+	s.notifyStopped(1, "node01", 4)
+	s.version++
+
+	changed := s.nodeLeave("node02")
+	assert.Equal(t, true, changed)
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node03", ModVersion: 5},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 6},
+	}, s.partitions)
+	assert.Equal(t, [][]string{{"node03"}, {"node01"}, {"node03"}}, s.replicas)
+}
+
+func TestGroupState_Replica_Zombie_Not_Promoted(t *testing.T) {
+	s := newGroupStateWithReplicas(2, 2)
+
+	s.nodeJoin("node01")
+	s.version++
+	s.nodeJoin("node02")
+	s.version++
+	s.nodeJoin("node03")
+	s.version++
+
+	s.partitions[0] = PartitionInfo{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 3}
+	s.replicas[0] = []string{"node02", "node03"}
+
+	s.nodeDisconnect("node02")
+	s.nodeExpired("node01")
+
+	assert.Equal(t, PartitionInfo{Status: PartitionStatusStarting, Owner: "node03", ModVersion: 4}, s.partitions[0])
+	assert.Equal(t, []string{"node02"}, s.replicas[0])
+}
+
+func TestGroupState_Replica_Count_Bounded_By_Nodes(t *testing.T) {
+	s := newGroupStateWithReplicas(1, 3)
+
+	s.nodeJoin("node01")
+	s.version++
+	s.nodeJoin("node02")
+	s.version++
+	s.nodeJoin("node03")
+	s.version++
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+	}, s.partitions)
+	assert.Equal(t, [][]string{{"node02", "node03"}}, s.toGroupData().Replicas)
+	assert.Equal(t, nil, Validate(s.toGroupData()))
+}
+
+func TestLocalClient_Replica_Listener(t *testing.T) {
+	l := New(WithReplicaCount(1))
+
+	var mut sync.Mutex
+	replicas := map[PartitionID][]string{}
+
+	client := NewLocalClient(l, "group01", "node01", 2,
+		WithClientReplicaListener(func(p PartitionID, r []string) {
+			mut.Lock()
+			replicas[p] = r
+			mut.Unlock()
+		}),
+	)
+	anotherClient := NewLocalClient(l, "group01", "node02", 2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		anotherClient.Run()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, map[PartitionID][]string{
+		0: {"node02"},
+		1: {"node01"},
+	}, replicas)
+	mut.Unlock()
+
+	client.Shutdown()
+	anotherClient.Shutdown()
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+package linken
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// PartitionOfKey maps key to a partition with the 64-bit FNV-1a hash, stable across processes and versions
+func PartitionOfKey(key []byte, count int) PartitionID {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	return PartitionID(h.Sum64() % uint64(count))
+}
+
+// PartitionOfString is PartitionOfKey with a string key
+func PartitionOfString(key string, count int) PartitionID {
+	return PartitionOfKey([]byte(key), count)
+}
+
+// Route is the result of routing a key
+type Route struct {
+	Partition PartitionID
+
+	// Owner is the node the partition is running on, empty if the partition is not running
+	Owner string
+	// Address is the address of Owner returned by the RouterAddressResolver, empty if not configured
+	Address string
+
+	// Version is the version of the group data used for routing
+	Version GroupVersion
+}
+
+// RouterAddressResolver returns the address of the node, empty if unknown.
+// Nodes do not advertise their addresses through the group, the group data only contains node names,
+// so the caller must supply the mapping, e.g. from the naming scheme of the nodes or a service registry
+type RouterAddressResolver func(node string) string
+
+// RouterOption ...
+type RouterOption func(r *Router)
+
+// WithRouterAddressResolver sets the resolver filling Route.Address, without it Route.Address is always empty
+func WithRouterAddressResolver(resolver RouterAddressResolver) RouterOption {
+	return func(r *Router) {
+		r.resolver = resolver
+	}
+}
+
+// Router maps keys to partitions and their running owners, using the latest group data passed to Update.
+// All methods are safe to be called from any goroutine
+type Router struct {
+	resolver RouterAddressResolver
+
+	// data stores a *GroupData, replaced as a whole by Update
+	data atomic.Value
+}
+
+// NewRouter creates a router without group data, use WithClientRouter to update it from a client
+func NewRouter(options ...RouterOption) *Router {
+	r := &Router{
+		resolver: func(node string) string { return "" },
+	}
+	for _, o := range options {
+		o(r)
+	}
+	return r
+}
+
+// Update replaces the group data used for routing
+func (r *Router) Update(data GroupData) {
+	r.data.Store(&data)
+}
+
+// Version returns the version of the group data used for routing, 0 if Update is not called yet
+func (r *Router) Version() GroupVersion {
+	data := r.load()
+	if data == nil {
+		return 0
+	}
+	return data.Version
+}
+
+func (r *Router) load() *GroupData {
+	data, _ := r.data.Load().(*GroupData)
+	return data
+}
+
+// Route returns the partition of key and its running owner,
+// ok is false if Update is not called yet or the partition is not running on any node
+func (r *Router) Route(key []byte) (route Route, ok bool) {
+	data := r.load()
+	if data == nil || len(data.Partitions) == 0 {
+		return Route{}, false
+	}
+
+	id := PartitionOfKey(key, len(data.Partitions))
+	route = Route{
+		Partition: id,
+		Version:   data.Version,
+	}
+
+	p := data.Partitions[id]
+	if p.Status != PartitionStatusRunning {
+		return route, false
+	}
+
+	route.Owner = p.Owner
+	route.Address = r.resolver(p.Owner)
+	return route, true
+}
+
+// RouteString is Route with a string key
+func (r *Router) RouteString(key string) (Route, bool) {
+	return r.Route([]byte(key))
+}
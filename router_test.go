@@ -0,0 +1,105 @@
+package linken
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPartitionOfString_Stable(t *testing.T) {
+	table := []struct {
+		key      string
+		expected PartitionID
+	}{
+		{key: "", expected: 5},
+		{key: "user-1", expected: 4},
+		{key: "user-2", expected: 5},
+		{key: "order-100", expected: 5},
+		{key: "abc", expected: 3},
+	}
+	for _, e := range table {
+		assert.Equal(t, e.expected, PartitionOfString(e.key, 8), e.key)
+		assert.Equal(t, e.expected, PartitionOfKey([]byte(e.key), 8), e.key)
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	r := NewRouter(WithRouterAddressResolver(func(node string) string {
+		if node == "node01" {
+			return "10.0.0.1:4000"
+		}
+		return ""
+	}))
+
+	route, ok := r.RouteString("abc")
+	assert.Equal(t, false, ok)
+	assert.Equal(t, Route{}, route)
+	assert.Equal(t, GroupVersion(0), r.Version())
+
+	partitions := make([]PartitionInfo, 8)
+	for i := range partitions {
+		partitions[i] = PartitionInfo{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 1}
+	}
+	partitions[4] = PartitionInfo{Status: PartitionStatusRunning, Owner: "node02", ModVersion: 2}
+	partitions[5] = PartitionInfo{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 3}
+
+	r.Update(GroupData{
+		Version:    3,
+		Nodes:      []string{"node01", "node02"},
+		Partitions: partitions,
+	})
+	assert.Equal(t, GroupVersion(3), r.Version())
+
+	route, ok = r.RouteString("abc")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, Route{
+		Partition: 3,
+		Owner:     "node01",
+		Address:   "10.0.0.1:4000",
+		Version:   3,
+	}, route)
+
+	route, ok = r.Route([]byte("user-1"))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, Route{
+		Partition: 4,
+		Owner:     "node02",
+		Version:   3,
+	}, route)
+
+	// NOT RUNNING
+	route, ok = r.RouteString("user-2")
+	assert.Equal(t, false, ok)
+	assert.Equal(t, Route{
+		Partition: 5,
+		Version:   3,
+	}, route)
+}
+
+func TestRouter_Updated_By_Client(t *testing.T) {
+	l := New()
+	r := NewRouter()
+
+	client := NewLocalClient(l, "group01", "node01", 8, WithClientRouter(r))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	route, ok := r.RouteString("abc")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, Route{
+		Partition: 3,
+		Owner:     "node01",
+		Version:   2,
+	}, route)
+
+	client.Shutdown()
+	wg.Wait()
+}
@@ -2,11 +2,14 @@ package linken
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // ServerCommandType ...
@@ -17,6 +20,8 @@ const (
 	ServerCommandTypeJoin ServerCommandType = "join"
 	// ServerCommandTypeNotify ...
 	ServerCommandTypeNotify ServerCommandType = "notify"
+	// ServerCommandTypeLeave leaves a group, only on connections negotiated CapabilityMultiGroup
+	ServerCommandTypeLeave ServerCommandType = "leave"
 )
 
 // ServerCommand ...
@@ -24,6 +29,9 @@ type ServerCommand struct {
 	Type   ServerCommandType     `json:"type"`
 	Join   *ServerJoinCommand    `json:"join"`
 	Notify []NotifyPartitionData `json:"notify"`
+
+	// Group is the group of the notify and leave commands, only on connections negotiated CapabilityMultiGroup
+	Group string `json:"group,omitempty"`
 }
 
 // ServerJoinCommand ...
@@ -33,12 +41,22 @@ type ServerJoinCommand struct {
 	PartitionCount int        `json:"partitionCount"`
 	Secret         string     `json:"secret"`
 	PrevState      *GroupData `json:"prevState"`
+
+	ProtocolVersions []ProtocolVersion `json:"protocolVersions,omitempty"`
+	Capabilities     []Capability      `json:"capabilities,omitempty"`
 }
 
 // ServerWatchRequest ...
 type ServerWatchRequest struct {
 	GroupName string `json:"groupName"`
 	Secret    string `json:"secret"`
+
+	// Filter limits the changes sent after the first group data
+	Filter *WatchFilter `json:"filter,omitempty"`
+
+	// History requests GroupHistory messages after the cursor instead of group data, see Linken.WatchHistory.
+	// The zero cursor starts from a snapshot, Filter is not used
+	History *HistoryCursor `json:"history,omitempty"`
 }
 
 // WebsocketHandler ...
@@ -86,24 +104,98 @@ func mergeContext(ctx context.Context, rootCtx context.Context) (context.Context
 
 // ServeHTTP ...
 func (h *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	identity, err := peerNodeIdentity(r.TLS, h.options.nodeIdentity)
+	if err != nil {
+		h.options.logger.Error("Invalid node identity", zap.Error(err))
+		http.Error(w, "invalid node identity", http.StatusUnauthorized)
+		return
+	}
+
 	ctx, cancel := mergeContext(r.Context(), h.rootCtx)
+	defer cancel()
 
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.options.logger.Error("Fail to upgrade to websocket", zap.Error(err))
 		return
 	}
+
+	h.serveConn(ctx, cancel, websocketConn{conn: conn}, identity)
+}
+
+// ServeStream accepts connections of the stream transport (see NewStreamTransport) from a TCP or Unix domain
+// socket listener. It returns after the listener is closed or Shutdown is called
+func (h *WebsocketHandler) ServeStream(ln net.Listener) error {
+	go func() {
+		<-h.rootCtx.Done()
+		_ = ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		netConn, err := ln.Accept()
+		if h.rootCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.serveStreamConn(netConn)
+		}()
+	}
+}
+
+func (h *WebsocketHandler) serveStreamConn(netConn net.Conn) {
+	var tlsState *tls.ConnectionState
+	if tlsConn, ok := netConn.(*tls.Conn); ok {
+		_ = tlsConn.SetDeadline(time.Now().Add(streamHandshakeTimeout))
+		err := tlsConn.Handshake()
+		_ = tlsConn.SetDeadline(time.Time{})
+		if err != nil {
+			h.options.logger.Error("TLS handshake failed", zap.Error(err))
+			_ = netConn.Close()
+			return
+		}
+		state := tlsConn.ConnectionState()
+		tlsState = &state
+	}
+
+	identity, err := peerNodeIdentity(tlsState, h.options.nodeIdentity)
+	if err != nil {
+		h.options.logger.Error("Invalid node identity", zap.Error(err))
+		_ = netConn.Close()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(h.rootCtx)
+	defer cancel()
+
+	h.serveConn(ctx, cancel, newStreamConn(netConn), identity)
+}
+
+func (h *WebsocketHandler) serveConn(ctx context.Context, cancel func(), conn Conn, identity *NodeIdentity) {
 	defer func() {
 		_ = conn.Close()
 	}()
 
-	sess, ok := h.handShake(conn)
+	sess, ok := h.handShake(conn, identity)
 	if !ok {
 		return
 	}
 
+	if sess.protocol.hasCapability(CapabilityMultiGroup) {
+		h.serveMultiGroupConn(ctx, cancel, conn, identity, sess)
+		return
+	}
+
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(3)
 
 	go func() {
 		defer wg.Done()
@@ -112,6 +204,12 @@ func (h *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.receiveNotify(h.rootCtx, sess, conn)
 	}()
 
+	go func() {
+		defer wg.Done()
+
+		h.closeWhenSuperseded(ctx, sess, conn)
+	}()
+
 	go func() {
 		defer wg.Done()
 		defer cancel()
@@ -122,11 +220,20 @@ func (h *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	wg.Wait()
 }
 
+var (
+	errGroupSecretNotExisted = errors.New("group secret not existed")
+	errInvalidWriteSecret    = errors.New("invalid 'secret' for write permission")
+	errInvalidReadSecret     = errors.New("invalid 'secret' for read permission")
+)
+
 type sessionData struct {
 	groupName      string
 	nodeName       string
 	partitionCount int
 	initVersion    GroupVersion
+	protocol       protocolInfo
+	session        NodeSession
+	filter         WatchFilter
 }
 
 func validatePrevState(prev *GroupData, partitionCount int) error {
@@ -140,13 +247,9 @@ func validatePrevState(prev *GroupData, partitionCount int) error {
 		return errors.New("previous state 'partitions' field is missing")
 	}
 
-	for _, p := range prev.Partitions {
-		if p.Status < 0 || p.Status > PartitionStatusStopping {
-			return errors.New("previous state partitions 'status' field is invalid")
-		}
-		if p.ModVersion > prev.Version {
-			return errors.New("previous state partitions 'modVersion' field is too big")
-		}
+	err := Validate(*prev)
+	if err != nil {
+		return errors.New("previous state " + err.Error())
 	}
 	return nil
 }
@@ -165,10 +268,10 @@ func validateJoinCmdBasicParams(join *ServerJoinCommand, groupSecrets map[string
 	if len(groupSecrets) > 0 {
 		secret, ok := groupSecrets[join.GroupName]
 		if !ok {
-			return errors.New("group secret not existed")
+			return errGroupSecretNotExisted
 		}
 		if join.Secret != secret.Write {
-			return errors.New("invalid 'secret' for write permission")
+			return errInvalidWriteSecret
 		}
 	}
 	return nil
@@ -213,27 +316,51 @@ func validateNotifyCmd(cmd ServerCommand, partitionCount int) error {
 	return nil
 }
 
-func (h *WebsocketHandler) handShake(conn *websocket.Conn) (sessionData, bool) {
+func (h *WebsocketHandler) rejectConn(conn Conn, serverErr *ServerError) {
 	logger := h.options.logger
 
-	var cmd ServerCommand
-	err := conn.ReadJSON(&cmd)
+	err := conn.WriteJSON(serverErrorReply{Error: serverErr})
 	if err != nil {
-		logger.Error("Error while ReadJSON", zap.Error(err))
-		return sessionData{}, false
+		logger.Error("Error while WriteJSON", zap.Error(err))
+		return
 	}
 
-	err = validateJoinCmd(cmd, h.options.groupSecrets)
+	err = conn.WriteClose(serverErr.closeCode(), string(serverErr.Code))
+	if err != nil {
+		logger.Error("Error while close conn", zap.Error(err))
+	}
+}
+
+func (h *WebsocketHandler) validateJoin(cmd ServerCommand, identity *NodeIdentity) *ServerError {
+	logger := h.options.logger
+
+	err := validateJoinCmd(cmd, h.options.groupSecrets)
 	if err != nil {
 		logger.Error("Validate Join Command", zap.Error(err))
-		return sessionData{}, false
+		return newServerError(joinCmdErrorCode(cmd, h.options.groupSecrets, err), err)
 	}
 
-	joinCmd := cmd.Join
-	err = h.linken.Join(joinCmd.GroupName, joinCmd.NodeName, joinCmd.PartitionCount, joinCmd.PrevState)
+	err = validateJoinIdentity(cmd.Join, identity)
 	if err != nil {
+		logger.Error("Validate Node Identity", zap.Error(err))
+		return newServerError(ServerErrorCodeUnauthorized, err)
+	}
+	return nil
+}
+
+// joinGroup joins the session of a validated join command and returns the first group data
+func (h *WebsocketHandler) joinGroup(joinCmd *ServerJoinCommand) (sessionData, GroupData, *ServerError) {
+	logger := h.options.logger
+
+	session, err := h.linken.JoinSession(
+		joinCmd.GroupName, joinCmd.NodeName, joinCmd.PartitionCount, joinCmd.PrevState)
+	if err == ErrInvalidPartitionCount {
 		logger.Error("Error while Join", zap.Error(err))
-		return sessionData{}, false
+		return sessionData{}, GroupData{}, newServerError(ServerErrorCodeInvalidPartitionCount, err)
+	}
+	if err != nil {
+		logger.Error("Error while Join", zap.Error(err))
+		return sessionData{}, GroupData{}, newServerError(ServerErrorCodeInternal, err)
 	}
 
 	ch := make(chan GroupData, 1)
@@ -244,26 +371,72 @@ func (h *WebsocketHandler) handShake(conn *websocket.Conn) (sessionData, bool) {
 
 	groupData := <-ch
 
-	err = conn.WriteJSON(groupData)
-	if err != nil {
-		logger.Error("Error while WriteJSON", zap.Error(err))
-		return sessionData{}, false
-	}
-
 	return sessionData{
 		groupName:      joinCmd.GroupName,
 		nodeName:       joinCmd.NodeName,
 		partitionCount: joinCmd.PartitionCount,
 		initVersion:    groupData.Version,
-	}, true
+		session:        session,
+	}, groupData, nil
 }
 
-func (h *WebsocketHandler) receiveNotify(ctx context.Context, sess sessionData, conn *websocket.Conn) {
+func (h *WebsocketHandler) handShake(conn Conn, identity *NodeIdentity) (sessionData, bool) {
+	logger := h.options.logger
+
+	var cmd ServerCommand
+	err := conn.ReadJSON(&cmd)
+	if err != nil {
+		logger.Error("Error while ReadJSON", zap.Error(err))
+		h.rejectConn(conn, newServerError(ServerErrorCodeInvalidCommand, err))
+		return sessionData{}, false
+	}
+
+	serverErr := h.validateJoin(cmd, identity)
+	if serverErr != nil {
+		h.rejectConn(conn, serverErr)
+		return sessionData{}, false
+	}
+
+	joinCmd := cmd.Join
+	protocol, err := negotiateProtocol(joinCmd.ProtocolVersions, joinCmd.Capabilities,
+		h.options.protocolVersions, h.options.capabilities)
+	if err != nil {
+		logger.Error("Negotiate Protocol", zap.Error(err))
+		h.rejectConn(conn, newServerError(ServerErrorCodeUnsupportedProtocol, err))
+		return sessionData{}, false
+	}
+
+	sess, groupData, serverErr := h.joinGroup(joinCmd)
+	if serverErr != nil {
+		h.rejectConn(conn, serverErr)
+		return sessionData{}, false
+	}
+	sess.protocol = protocol
+
+	resp := ServerResponse{GroupData: groupData}
+	if len(joinCmd.ProtocolVersions) > 0 {
+		resp.ProtocolVersion = protocol.version
+		resp.Capabilities = protocol.capabilities
+	}
+	if protocol.hasCapability(CapabilityMultiGroup) {
+		resp.Group = joinCmd.GroupName
+	}
+
+	err = conn.WriteJSON(resp)
+	if err != nil {
+		logger.Error("Error while WriteJSON", zap.Error(err))
+		return sessionData{}, false
+	}
+
+	return sess, true
+}
+
+func (h *WebsocketHandler) receiveNotify(ctx context.Context, sess sessionData, conn Conn) {
 	logger := h.options.logger
 	gracefulClosed := false
 	defer func() {
 		if !gracefulClosed {
-			h.linken.Disconnect(sess.groupName, sess.nodeName)
+			h.linken.DisconnectSession(sess.groupName, sess.nodeName, sess.session.ID)
 		}
 	}()
 
@@ -271,13 +444,13 @@ func (h *WebsocketHandler) receiveNotify(ctx context.Context, sess sessionData,
 		var cmd ServerCommand
 		err := conn.ReadJSON(&cmd)
 		if ctx.Err() != nil {
-			h.linken.Leave(sess.groupName, sess.nodeName)
+			h.linken.LeaveSession(sess.groupName, sess.nodeName, sess.session.ID)
 			gracefulClosed = true
 			return
 		}
 		if err != nil {
 			if errorIsCloseNormal(err) {
-				h.linken.Leave(sess.groupName, sess.nodeName)
+				h.linken.LeaveSession(sess.groupName, sess.nodeName, sess.session.ID)
 				gracefulClosed = true
 				return
 			}
@@ -292,46 +465,63 @@ func (h *WebsocketHandler) receiveNotify(ctx context.Context, sess sessionData,
 			return
 		}
 
-		h.linken.Notify(sess.groupName, sess.nodeName, cmd.Notify)
+		h.linken.NotifySession(sess.groupName, sess.nodeName, sess.session.ID, cmd.Notify)
 	}
 }
 
-func (h *WebsocketHandler) sendStateUpdate(ctx context.Context, sess sessionData, conn *websocket.Conn) {
+func (h *WebsocketHandler) sendStateUpdate(ctx context.Context, sess sessionData, conn Conn) {
 	logger := h.options.logger
 	defer closeConnGracefully(h.rootCtx, conn, logger)
 
-	fromVersion := sess.initVersion + 1
-	ch := make(chan GroupData, 1)
-
-	for {
-		h.linken.Watch(sess.groupName, WatchRequest{
-			FromVersion:  fromVersion,
-			ResponseChan: ch,
-		})
+	for data := range h.linken.WatchFilteredContext(ctx, sess.groupName, sess.initVersion+1, sess.filter) {
+		err := conn.WriteJSON(data)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("Error while WriteJSON", zap.Error(err))
+			return
+		}
+	}
+}
 
-		select {
-		case data := <-ch:
-			err := conn.WriteJSON(data)
-			if ctx.Err() != nil {
-				return
-			}
-			if err != nil {
-				logger.Error("Error while WriteJSON", zap.Error(err))
-				return
-			}
-			fromVersion = data.Version + 1
+func (h *WebsocketHandler) sendHistoryUpdate(ctx context.Context, groupName string, cursor HistoryCursor, conn Conn) {
+	logger := h.options.logger
+	defer closeConnGracefully(h.rootCtx, conn, logger)
 
-		case <-ctx.Done():
-			h.linken.RemoveWatch(sess.groupName, ch)
+	for history := range h.linken.WatchHistory(ctx, groupName, cursor) {
+		err := conn.WriteJSON(history)
+		if ctx.Err() != nil {
 			return
 		}
+		if err != nil {
+			logger.Error("Error while WriteJSON", zap.Error(err))
+			return
+		}
+	}
+}
+
+// closeWhenSuperseded closes the connection after the node joined again with a newer connection
+func (h *WebsocketHandler) closeWhenSuperseded(ctx context.Context, sess sessionData, conn Conn) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-sess.session.Terminated:
+	}
+
+	h.options.logger.Warn("Session superseded",
+		zap.String("group", sess.groupName), zap.String("node", sess.nodeName))
+
+	err := conn.WriteClose(ClosePolicyViolation, string(ServerErrorCodeSessionSuperseded))
+	if err != nil {
+		h.options.logger.Error("Error while close conn", zap.Error(err))
 	}
+	_ = conn.Close()
 }
 
-func closeConnGracefully(rootCtx context.Context, conn *websocket.Conn, logger *zap.Logger) {
+func closeConnGracefully(rootCtx context.Context, conn Conn, logger *zap.Logger) {
 	if rootCtx.Err() != nil {
-		err := conn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		err := conn.WriteClose(CloseNormal, "")
 		if err != nil {
 			logger.Error("Error while close conn", zap.Error(err))
 		}
@@ -344,11 +534,12 @@ func (h *WebsocketHandler) readonlyFunc(w http.ResponseWriter, r *http.Request)
 	ctx, cancel := mergeContext(r.Context(), h.rootCtx)
 	defer cancel()
 
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	wsConn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logger.Error("Fail to upgrade to websocket", zap.Error(err))
 		return
 	}
+	conn := websocketConn{conn: wsConn}
 	defer func() {
 		_ = conn.Close()
 	}()
@@ -366,7 +557,16 @@ func (h *WebsocketHandler) readonlyFunc(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	h.sendStateUpdate(ctx, sessionData{groupName: req.GroupName}, conn)
+	if req.History != nil {
+		h.sendHistoryUpdate(ctx, req.GroupName, *req.History, conn)
+		return
+	}
+
+	sess := sessionData{groupName: req.GroupName}
+	if req.Filter != nil {
+		sess.filter = *req.Filter
+	}
+	h.sendStateUpdate(ctx, sess, conn)
 }
 
 // Readonly ...
@@ -374,14 +574,6 @@ func (h *WebsocketHandler) Readonly() http.Handler {
 	return http.HandlerFunc(h.readonlyFunc)
 }
 
-func errorIsCloseNormal(err error) bool {
-	var closeErr *websocket.CloseError
-	if !errors.As(err, &closeErr) {
-		return false
-	}
-	return closeErr.Code == websocket.CloseNormalClosure
-}
-
 func validateReadonlyCommand(req ServerWatchRequest, secrets map[string]GroupSecret) error {
 	if len(req.GroupName) == 0 {
 		return errors.New("groupName must not be empty")
@@ -389,10 +581,10 @@ func validateReadonlyCommand(req ServerWatchRequest, secrets map[string]GroupSec
 	if len(secrets) > 0 {
 		secret, ok := secrets[req.GroupName]
 		if !ok {
-			return errors.New("group secret not existed")
+			return errGroupSecretNotExisted
 		}
 		if req.Secret != secret.Read {
-			return errors.New("invalid 'secret' for read permission")
+			return errInvalidReadSecret
 		}
 	}
 	return nil
@@ -0,0 +1,107 @@
+package linken
+
+import (
+	"errors"
+)
+
+// ServerErrorCode ...
+type ServerErrorCode string
+
+const (
+	// ServerErrorCodeInvalidCommand ...
+	ServerErrorCodeInvalidCommand ServerErrorCode = "invalid_command"
+	// ServerErrorCodeUnauthorized ...
+	ServerErrorCodeUnauthorized ServerErrorCode = "unauthorized"
+	// ServerErrorCodeInvalidPartitionCount ...
+	ServerErrorCodeInvalidPartitionCount ServerErrorCode = "invalid_partition_count"
+	// ServerErrorCodeInvalidPrevState ...
+	ServerErrorCodeInvalidPrevState ServerErrorCode = "invalid_prev_state"
+	// ServerErrorCodeUnsupportedProtocol ...
+	ServerErrorCodeUnsupportedProtocol ServerErrorCode = "unsupported_protocol"
+	// ServerErrorCodeSessionSuperseded is the close reason when a node joined again with another connection
+	ServerErrorCodeSessionSuperseded ServerErrorCode = "session_superseded"
+	// ServerErrorCodeInternal ...
+	ServerErrorCodeInternal ServerErrorCode = "internal"
+)
+
+// ServerError is the typed error replied by the server before closing a rejected connection
+type ServerError struct {
+	Code    ServerErrorCode `json:"code"`
+	Message string          `json:"message"`
+}
+
+var _ error = &ServerError{}
+
+func (e *ServerError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// Permanent returns true if retrying with the same parameters will fail again
+func (e *ServerError) Permanent() bool {
+	switch e.Code {
+	case ServerErrorCodeInternal, ServerErrorCodeSessionSuperseded:
+		return false
+	default:
+		return true
+	}
+}
+
+func (e *ServerError) closeCode() int {
+	switch e.Code {
+	case ServerErrorCodeUnauthorized, ServerErrorCodeInvalidPartitionCount, ServerErrorCodeUnsupportedProtocol:
+		return ClosePolicyViolation
+	case ServerErrorCodeInvalidCommand, ServerErrorCodeInvalidPrevState:
+		return CloseInvalidPayload
+	default:
+		return CloseInternalError
+	}
+}
+
+// ServerResponse is the message sent from the server to nodes
+type ServerResponse struct {
+	// Group is the group of the message, only on connections negotiated CapabilityMultiGroup
+	Group string `json:"group,omitempty"`
+
+	GroupData
+	Error *ServerError `json:"error,omitempty"`
+
+	// protocol negotiation result, only in the first response to a join command containing protocol versions
+	ProtocolVersion ProtocolVersion `json:"protocolVersion,omitempty"`
+	Capabilities    []Capability    `json:"capabilities,omitempty"`
+}
+
+type serverErrorReply struct {
+	Error *ServerError `json:"error"`
+}
+
+type serverGroupErrorReply struct {
+	Group string       `json:"group"`
+	Error *ServerError `json:"error"`
+}
+
+func newServerError(code ServerErrorCode, err error) *ServerError {
+	return &ServerError{
+		Code:    code,
+		Message: err.Error(),
+	}
+}
+
+// IsPermanentError returns true if err is a *ServerError that is permanent
+func IsPermanentError(err error) bool {
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	return serverErr.Permanent()
+}
+
+func joinCmdErrorCode(cmd ServerCommand, groupSecrets map[string]GroupSecret, err error) ServerErrorCode {
+	if errors.Is(err, errGroupSecretNotExisted) || errors.Is(err, errInvalidWriteSecret) {
+		return ServerErrorCodeUnauthorized
+	}
+	if cmd.Type == ServerCommandTypeJoin && cmd.Join != nil && cmd.Join.PrevState != nil &&
+		validateJoinCmdBasicParams(cmd.Join, groupSecrets) == nil {
+		return ServerErrorCodeInvalidPrevState
+	}
+	return ServerErrorCodeInvalidCommand
+}
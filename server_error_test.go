@@ -0,0 +1,79 @@
+package linken
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJoinCmdErrorCode(t *testing.T) {
+	secrets := map[string]GroupSecret{
+		"group01": {Write: "write-secret"},
+	}
+
+	table := []struct {
+		name string
+		cmd  ServerCommand
+		code ServerErrorCode
+	}{
+		{
+			name: "wrong-type",
+			cmd:  ServerCommand{Type: ServerCommandTypeNotify},
+			code: ServerErrorCodeInvalidCommand,
+		},
+		{
+			name: "missing-group-secret",
+			cmd: ServerCommand{
+				Type: ServerCommandTypeJoin,
+				Join: &ServerJoinCommand{
+					GroupName:      "group02",
+					NodeName:       "node01",
+					PartitionCount: 3,
+				},
+			},
+			code: ServerErrorCodeUnauthorized,
+		},
+		{
+			name: "invalid-secret",
+			cmd: ServerCommand{
+				Type: ServerCommandTypeJoin,
+				Join: &ServerJoinCommand{
+					GroupName:      "group01",
+					NodeName:       "node01",
+					PartitionCount: 3,
+					Secret:         "other",
+				},
+			},
+			code: ServerErrorCodeUnauthorized,
+		},
+		{
+			name: "invalid-prev-state",
+			cmd: ServerCommand{
+				Type: ServerCommandTypeJoin,
+				Join: &ServerJoinCommand{
+					GroupName:      "group01",
+					NodeName:       "node01",
+					PartitionCount: 3,
+					Secret:         "write-secret",
+					PrevState:      &GroupData{},
+				},
+			},
+			code: ServerErrorCodeInvalidPrevState,
+		},
+	}
+
+	for _, e := range table {
+		t.Run(e.name, func(t *testing.T) {
+			err := validateJoinCmd(e.cmd, secrets)
+			assert.NotEqual(t, nil, err)
+			assert.Equal(t, e.code, joinCmdErrorCode(e.cmd, secrets, err))
+		})
+	}
+}
+
+func TestIsPermanentError(t *testing.T) {
+	assert.Equal(t, false, IsPermanentError(nil))
+	assert.Equal(t, false, IsPermanentError(errors.New("some error")))
+	assert.Equal(t, false, IsPermanentError(&ServerError{Code: ServerErrorCodeInternal}))
+	assert.Equal(t, true, IsPermanentError(&ServerError{Code: ServerErrorCodeUnauthorized}))
+}
@@ -70,6 +70,25 @@ func assertCloseEOF(t *testing.T, conn *websocket.Conn) {
 	assert.Equal(t, "", string(data))
 }
 
+func assertServerError(t *testing.T, conn *websocket.Conn, expected string, closeCode int) {
+	t.Helper()
+
+	text := connReadText(t, conn)
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(text))
+
+	var resp ServerResponse
+	err := json.Unmarshal([]byte(text), &resp)
+	assert.Equal(t, nil, err)
+
+	msgType, data, err := conn.ReadMessage()
+	assert.Equal(t, &websocket.CloseError{
+		Code: closeCode,
+		Text: string(resp.Error.Code),
+	}, err)
+	assert.Equal(t, -1, msgType)
+	assert.Equal(t, "", string(data))
+}
+
 func newTestCase(options ...Option) *testCase {
 	logger, err := zap.NewDevelopment()
 	if err != nil {
@@ -186,6 +205,7 @@ func TestWebsocketHandler_HandShake_Normal(t *testing.T) {
 	expected := `
 {
   "version": 1,
+  "epoch": 1,
   "nodes": [
     "node01"
   ],
@@ -217,6 +237,80 @@ func TestWebsocketHandler_HandShake_Normal(t *testing.T) {
 	tc.handler.Shutdown()
 }
 
+func TestWebsocketHandler_HandShake_Protocol_Version(t *testing.T) {
+	tc := newTestCase(WithCapabilities("feature-a", "feature-b"))
+	defer tc.shutdown()
+
+	conn := connectToServer()
+	defer func() { _ = conn.Close() }()
+
+	connWriteText(t, conn, `
+{
+  "type": "join",
+  "join": {
+    "groupName": "group01",
+    "nodeName": "node01",
+    "partitionCount": 1,
+    "protocolVersions": [1, 2],
+    "capabilities": ["feature-b", "feature-c"]
+  }
+}
+`)
+
+	expected := `
+{
+  "version": 1,
+  "epoch": 1,
+  "nodes": [
+    "node01"
+  ],
+  "partitions": [
+    {
+      "status": 1,
+      "owner": "node01",
+      "nextOwner": "",
+      "modVersion": 1
+    }
+  ],
+  "protocolVersion": 1,
+  "capabilities": [
+    "feature-b"
+  ]
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(connReadText(t, conn)))
+
+	closeWebsocket(t, conn)
+}
+
+func TestWebsocketHandler_HandShake_Unsupported_Protocol_Version(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	conn := connectToServer()
+	defer func() { _ = conn.Close() }()
+
+	connWriteText(t, conn, `
+{
+  "type": "join",
+  "join": {
+    "groupName": "group01",
+    "nodeName": "node01",
+    "partitionCount": 1,
+    "protocolVersions": [2]
+  }
+}
+`)
+	assertServerError(t, conn, `
+{
+  "error": {
+    "code": "unsupported_protocol",
+    "message": "no mutually supported protocol version"
+  }
+}
+`, websocket.ClosePolicyViolation)
+}
+
 func TestWebsocketHandler_HandShake_JSON_Error(t *testing.T) {
 	tc := newTestCase()
 	defer tc.shutdown()
@@ -227,13 +321,14 @@ func TestWebsocketHandler_HandShake_JSON_Error(t *testing.T) {
 	joinReq := `123`
 	_ = conn.WriteMessage(websocket.TextMessage, []byte(joinReq))
 
-	msgType, data, err := conn.ReadMessage()
-	assert.Equal(t, &websocket.CloseError{
-		Code: websocket.CloseAbnormalClosure,
-		Text: "unexpected EOF",
-	}, err)
-	assert.Equal(t, -1, msgType)
-	assert.Equal(t, "", string(data))
+	assertServerError(t, conn, `
+{
+  "error": {
+    "code": "invalid_command",
+    "message": "json: cannot unmarshal number into Go value of type linken.ServerCommand"
+  }
+}
+`, websocket.CloseInvalidFramePayloadData)
 }
 
 func TestWebsocketHandler_HandShake_Wrong_Type(t *testing.T) {
@@ -251,13 +346,14 @@ func TestWebsocketHandler_HandShake_Wrong_Type(t *testing.T) {
 `
 	_ = conn.WriteMessage(websocket.TextMessage, []byte(joinReq))
 
-	msgType, data, err := conn.ReadMessage()
-	assert.Equal(t, &websocket.CloseError{
-		Code: websocket.CloseAbnormalClosure,
-		Text: "unexpected EOF",
-	}, err)
-	assert.Equal(t, -1, msgType)
-	assert.Equal(t, "", string(data))
+	assertServerError(t, conn, `
+{
+  "error": {
+    "code": "invalid_command",
+    "message": "invalid cmd type, must be 'join'"
+  }
+}
+`, websocket.CloseInvalidFramePayloadData)
 }
 
 func TestWebsocketHandler_HandShake_With_PrevState(t *testing.T) {
@@ -307,6 +403,7 @@ func TestWebsocketHandler_HandShake_With_PrevState(t *testing.T) {
 	expected := `
 {
   "version": 11,
+  "epoch": 1,
   "nodes": [
     "node01",
     "node02"
@@ -372,6 +469,7 @@ func TestWebsocketHandler_Notify(t *testing.T) {
 	expected := `
 {
   "version": 2,
+  "epoch": 1,
   "nodes": [
     "node01"
   ],
@@ -425,6 +523,7 @@ func TestWebsocketHandler_Client_Closed_Unexpected(t *testing.T) {
 	expected := `
 {
   "version": 3,
+  "epoch": 1,
   "nodes": [
     "node02"
   ],
@@ -480,6 +579,43 @@ func TestWebsocketHandler_Two_Clients_Closed(t *testing.T) {
 	tc.handler.linken.mut.RUnlock()
 }
 
+func TestWebsocketHandler_Same_Node_Join_Again(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	conn1 := connectToServer()
+	defer func() { _ = conn1.Close() }()
+
+	joinNodeForTest(t, conn1, "group01", "node01", 3)
+
+	conn2 := connectToServer()
+	defer func() { _ = conn2.Close() }()
+
+	joinNodeForTest(t, conn2, "group01", "node01", 3)
+
+	msgType, data, err := conn1.ReadMessage()
+	assert.Equal(t, &websocket.CloseError{
+		Code: websocket.ClosePolicyViolation,
+		Text: "session_superseded",
+	}, err)
+	assert.Equal(t, -1, msgType)
+	assert.Equal(t, "", string(data))
+
+	time.Sleep(20 * time.Millisecond)
+
+	tc.handler.linken.mut.RLock()
+	group := tc.handler.linken.groups["group01"]
+	tc.handler.linken.mut.RUnlock()
+
+	group.mut.Lock()
+	assert.Equal(t, map[string]nodeInfo{
+		"node01": {status: nodeStatusAlive},
+	}, group.state.nodes)
+	group.mut.Unlock()
+
+	closeWebsocket(t, conn2)
+}
+
 func TestWebsocketHandler_Notify_Failed_Validation(t *testing.T) {
 	tc := newTestCase()
 	defer tc.shutdown()
@@ -546,6 +682,7 @@ func TestWebsocketHandler_Multiple_Group(t *testing.T) {
 	expected := `
 {
   "version": 1,
+  "epoch": 2,
   "nodes": [
     "node01"
   ],
@@ -572,6 +709,7 @@ func TestWebsocketHandler_Multiple_Group(t *testing.T) {
 	expected = `
 {
   "version": 1,
+  "epoch": 1,
   "nodes": [
     "node02"
   ],
@@ -618,7 +756,48 @@ func TestWebsocketHandler_Join_Failed_Secret(t *testing.T) {
   }
 }
 `)
-	assertCloseEOF(t, conn)
+	assertServerError(t, conn, `
+{
+  "error": {
+    "code": "unauthorized",
+    "message": "invalid 'secret' for write permission"
+  }
+}
+`, websocket.ClosePolicyViolation)
+}
+
+func TestWebsocketHandler_Join_Failed_Partition_Count(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	conn1 := connectToServer()
+	defer func() { _ = conn1.Close() }()
+
+	joinNodeForTest(t, conn1, "group01", "node01", 3)
+
+	conn2 := connectToServer()
+	defer func() { _ = conn2.Close() }()
+
+	connWriteText(t, conn2, `
+{
+  "type": "join",
+  "join": {
+    "groupName": "group01",
+    "nodeName": "node02",
+    "partitionCount": 4
+  }
+}
+`)
+	assertServerError(t, conn2, `
+{
+  "error": {
+    "code": "invalid_partition_count",
+    "message": "number of partitions not matched"
+  }
+}
+`, websocket.ClosePolicyViolation)
+
+	closeWebsocket(t, conn1)
 }
 
 func TestWebsocketHandler_Join_OK_With_Secret(t *testing.T) {
@@ -668,6 +847,7 @@ func TestWebsocketHandler_Readonly_Single_Node(t *testing.T) {
 	expected := `
 {
   "version": 1,
+  "epoch": 1,
   "nodes": [
     "node01"
   ],
@@ -696,6 +876,73 @@ func TestWebsocketHandler_Readonly_Single_Node(t *testing.T) {
 	assert.Equal(t, strings.TrimSpace(expected), formatJSON(resp))
 }
 
+func TestWebsocketHandler_Readonly_History(t *testing.T) {
+	tc := newTestCase()
+	defer tc.shutdown()
+
+	conn := connectToServer()
+	defer func() { _ = conn.Close() }()
+
+	joinNodeForTest(t, conn, "group01", "node01", 1)
+
+	read := connectToServerReadonly()
+	defer func() { _ = read.Close() }()
+
+	connWriteText(t, read, `
+{
+  "groupName": "group01",
+  "history": {}
+}
+`)
+
+	resp := connReadText(t, read)
+	expected := `
+{
+  "epoch": 1,
+  "version": 1,
+  "nextSeq": 3,
+  "snapshot": {
+    "version": 1,
+    "epoch": 1,
+    "nodes": [
+      "node01"
+    ],
+    "partitions": [
+      {
+        "status": 1,
+        "owner": "node01",
+        "nextOwner": "",
+        "modVersion": 1
+      }
+    ]
+  }
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(resp))
+
+	conn2 := connectToServer()
+	defer func() { _ = conn2.Close() }()
+
+	joinNodeForTest(t, conn2, "group01", "node02", 1)
+
+	resp = connReadText(t, read)
+	expected = `
+{
+  "epoch": 1,
+  "version": 2,
+  "nextSeq": 4,
+  "events": [
+    {
+      "version": 2,
+      "type": "node_joined",
+      "node": "node02"
+    }
+  ]
+}
+`
+	assert.Equal(t, strings.TrimSpace(expected), formatJSON(resp))
+}
+
 func TestWebsocketHandler_Readonly_Failed_Group_Empty(t *testing.T) {
 	tc := newTestCase()
 	defer tc.shutdown()
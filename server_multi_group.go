@@ -0,0 +1,288 @@
+package linken
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"sync"
+)
+
+var errGroupAlreadyJoined = errors.New("group already joined on this connection")
+
+// lockedConn serializes the writes of a connection shared by the goroutines of multiple groups
+type lockedConn struct {
+	Conn
+	mut sync.Mutex
+}
+
+func (c *lockedConn) WriteJSON(v interface{}) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+func (c *lockedConn) WriteClose(code int, reason string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.Conn.WriteClose(code, reason)
+}
+
+// multiGroupConn serves a connection negotiated CapabilityMultiGroup, each joined group has its own session.
+// A failed join or a superseded session only affects its group, the connection is kept
+type multiGroupConn struct {
+	h        *WebsocketHandler
+	conn     Conn
+	identity *NodeIdentity
+	protocol protocolInfo
+
+	wg     sync.WaitGroup
+	mut    sync.Mutex
+	groups map[string]*multiGroupSession
+}
+
+type multiGroupSession struct {
+	sess   sessionData
+	cancel func()
+}
+
+func (h *WebsocketHandler) serveMultiGroupConn(
+	ctx context.Context, cancel func(), conn Conn, identity *NodeIdentity, first sessionData,
+) {
+	m := &multiGroupConn{
+		h:        h,
+		conn:     &lockedConn{Conn: conn},
+		identity: identity,
+		protocol: first.protocol,
+		groups:   map[string]*multiGroupSession{},
+	}
+	m.startGroup(ctx, first)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		<-ctx.Done()
+		closeConnGracefully(h.rootCtx, m.conn, h.options.logger)
+	}()
+
+	m.receiveCommands(ctx)
+	cancel()
+	m.wg.Wait()
+}
+
+func (m *multiGroupConn) receiveCommands(ctx context.Context) {
+	logger := m.h.options.logger
+	gracefulClosed := false
+	defer func() {
+		m.stopAll(gracefulClosed)
+	}()
+
+	for {
+		var cmd ServerCommand
+		err := m.conn.ReadJSON(&cmd)
+		if m.h.rootCtx.Err() != nil {
+			gracefulClosed = true
+			return
+		}
+		if err != nil {
+			if errorIsCloseNormal(err) {
+				gracefulClosed = true
+				return
+			}
+
+			logger.Error("Error while ReadJSON", zap.Error(err))
+			return
+		}
+
+		if !m.handleCommand(ctx, cmd) {
+			return
+		}
+	}
+}
+
+// handleCommand returns false if the connection must be closed
+func (m *multiGroupConn) handleCommand(ctx context.Context, cmd ServerCommand) bool {
+	switch cmd.Type {
+	case ServerCommandTypeJoin:
+		return m.join(ctx, cmd)
+	case ServerCommandTypeLeave:
+		m.leave(cmd.Group)
+		return true
+	case ServerCommandTypeNotify:
+		return m.notify(cmd)
+	default:
+		m.h.options.logger.Error("Invalid command type", zap.String("type", string(cmd.Type)))
+		return false
+	}
+}
+
+func (m *multiGroupConn) join(ctx context.Context, cmd ServerCommand) bool {
+	logger := m.h.options.logger
+
+	groupName := ""
+	if cmd.Join != nil {
+		groupName = cmd.Join.GroupName
+	}
+
+	serverErr := m.h.validateJoin(cmd, m.identity)
+	if serverErr == nil && m.getGroup(groupName) != nil {
+		serverErr = newServerError(ServerErrorCodeInvalidCommand, errGroupAlreadyJoined)
+	}
+
+	var sess sessionData
+	var groupData GroupData
+	if serverErr == nil {
+		sess, groupData, serverErr = m.h.joinGroup(cmd.Join)
+	}
+
+	if serverErr != nil {
+		err := m.conn.WriteJSON(serverGroupErrorReply{Group: groupName, Error: serverErr})
+		if err != nil {
+			logger.Error("Error while WriteJSON", zap.Error(err))
+			return false
+		}
+		return true
+	}
+	sess.protocol = m.protocol
+
+	err := m.conn.WriteJSON(ServerResponse{Group: groupName, GroupData: groupData})
+	m.startGroup(ctx, sess)
+	if err != nil {
+		logger.Error("Error while WriteJSON", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+func (m *multiGroupConn) leave(groupName string) {
+	g := m.removeGroup(groupName, nil)
+	if g == nil {
+		return
+	}
+	m.h.linken.LeaveSession(groupName, g.sess.nodeName, g.sess.session.ID)
+}
+
+func (m *multiGroupConn) notify(cmd ServerCommand) bool {
+	logger := m.h.options.logger
+
+	g := m.getGroup(cmd.Group)
+	if g == nil {
+		// the session could be superseded right before the notify command
+		logger.Warn("Notify a group not joined", zap.String("group", cmd.Group))
+		return true
+	}
+
+	err := validateNotifyCmd(cmd, g.sess.partitionCount)
+	if err != nil {
+		logger.Error("Validate Notify Command", zap.Error(err))
+		return false
+	}
+
+	m.h.linken.NotifySession(cmd.Group, g.sess.nodeName, g.sess.session.ID, cmd.Notify)
+	return true
+}
+
+func (m *multiGroupConn) getGroup(groupName string) *multiGroupSession {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.groups[groupName]
+}
+
+// removeGroup removes and stops the group, only if its session is the same as sess when sess is not nil
+func (m *multiGroupConn) removeGroup(groupName string, sess *NodeSession) *multiGroupSession {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	g, ok := m.groups[groupName]
+	if !ok {
+		return nil
+	}
+	if sess != nil && g.sess.session.ID != sess.ID {
+		return nil
+	}
+	delete(m.groups, groupName)
+	g.cancel()
+	return g
+}
+
+func (m *multiGroupConn) stopAll(gracefulClosed bool) {
+	m.mut.Lock()
+	groups := m.groups
+	m.groups = map[string]*multiGroupSession{}
+	m.mut.Unlock()
+
+	for groupName, g := range groups {
+		g.cancel()
+		if gracefulClosed {
+			m.h.linken.LeaveSession(groupName, g.sess.nodeName, g.sess.session.ID)
+		} else {
+			m.h.linken.DisconnectSession(groupName, g.sess.nodeName, g.sess.session.ID)
+		}
+	}
+}
+
+func (m *multiGroupConn) startGroup(ctx context.Context, sess sessionData) {
+	groupCtx, cancel := context.WithCancel(ctx)
+
+	m.mut.Lock()
+	m.groups[sess.groupName] = &multiGroupSession{sess: sess, cancel: cancel}
+	m.mut.Unlock()
+
+	m.wg.Add(2)
+
+	go func() {
+		defer m.wg.Done()
+
+		m.sendGroupUpdate(groupCtx, sess)
+	}()
+
+	go func() {
+		defer m.wg.Done()
+
+		m.removeWhenSuperseded(groupCtx, sess)
+	}()
+}
+
+func (m *multiGroupConn) sendGroupUpdate(ctx context.Context, sess sessionData) {
+	logger := m.h.options.logger
+
+	for data := range m.h.linken.WatchFilteredContext(ctx, sess.groupName, sess.initVersion+1, sess.filter) {
+		err := m.conn.WriteJSON(ServerResponse{Group: sess.groupName, GroupData: data})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("Error while WriteJSON", zap.Error(err))
+			_ = m.conn.Close()
+			return
+		}
+	}
+}
+
+// removeWhenSuperseded removes the group from the connection after the node joined again with a newer connection
+func (m *multiGroupConn) removeWhenSuperseded(ctx context.Context, sess sessionData) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-sess.session.Terminated:
+	}
+
+	logger := m.h.options.logger
+	logger.Warn("Session superseded",
+		zap.String("group", sess.groupName), zap.String("node", sess.nodeName))
+
+	if m.removeGroup(sess.groupName, &sess.session) == nil {
+		return
+	}
+
+	err := m.conn.WriteJSON(serverGroupErrorReply{
+		Group: sess.groupName,
+		Error: &ServerError{
+			Code:    ServerErrorCodeSessionSuperseded,
+			Message: "node joined again with another connection",
+		},
+	})
+	if err != nil {
+		logger.Error("Error while WriteJSON", zap.Error(err))
+	}
+}
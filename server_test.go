@@ -183,6 +183,35 @@ func TestValidateJoinCmd(t *testing.T) {
 			},
 			err: errors.New("previous state partitions 'modVersion' field is too big"),
 		},
+		{
+			name: "prev-state-next-owner-not-in-nodes",
+			cmd: ServerCommand{
+				Type: ServerCommandTypeJoin,
+				Join: &ServerJoinCommand{
+					GroupName:      "some-group",
+					NodeName:       "some-node",
+					PartitionCount: 2,
+					PrevState: &GroupData{
+						Version: 10,
+						Nodes:   []string{"node01"},
+						Partitions: []PartitionInfo{
+							{
+								Status:     PartitionStatusRunning,
+								Owner:      "node01",
+								ModVersion: 8,
+							},
+							{
+								Status:     PartitionStatusStopping,
+								Owner:      "node01",
+								NextOwner:  "node02",
+								ModVersion: 9,
+							},
+						},
+					},
+				},
+			},
+			err: errors.New("previous state partitions 'nextOwner' field 'node02' is not in nodes"),
+		},
 		{
 			name: "invalid-join-secret",
 			cmd: ServerCommand{
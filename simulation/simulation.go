@@ -0,0 +1,552 @@
+// Package simulation drives a Linken group with many virtual nodes doing random operations,
+// using a seeded random generator and a fake clock, and checks the safety invariants after every step.
+// A failing seed can be replayed, the same seed always produces the same steps:
+//
+//	go test ./simulation -run TestSimulation -seed=<seed>
+package simulation
+
+import (
+	"fmt"
+	"github.com/QuangTung97/linken"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const groupName = "simulation"
+
+// Config ...
+type Config struct {
+	Seed       int64
+	Nodes      int
+	Partitions int
+	Steps      int
+
+	// ExpiredDuration is the node expired duration of the server,
+	// a disconnected node always reconnects before this duration passed
+	ExpiredDuration time.Duration
+
+	// Replicas is the replica count of the server, see linken.WithReplicaCount
+	Replicas int
+}
+
+// DefaultConfig returns the config used by the tests, only Seed needs to be set
+func DefaultConfig(seed int64) Config {
+	return Config{
+		Seed:            seed,
+		Nodes:           5,
+		Partitions:      7,
+		Steps:           2000,
+		ExpiredDuration: 30 * time.Second,
+	}
+}
+
+// ViolationError is returned when an invariant is violated, Steps contains the log of the steps until the violation
+type ViolationError struct {
+	Seed    int64
+	Step    int
+	Message string
+	Steps   []string
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("seed %d, step %d: %s\n%s", e.Seed, e.Step, e.Message, strings.Join(e.Steps, "\n"))
+}
+
+type nodeStatus int
+
+const (
+	// the node is not in the group, and is not running any partition
+	nodeStatusDown nodeStatus = iota
+	// the node joined the current server
+	nodeStatusJoined
+	// the node lost its connection, but still running its partitions and will reconnect before expired
+	nodeStatusReconnecting
+)
+
+type virtualNode struct {
+	name   string
+	status nodeStatus
+
+	sess linken.NodeSession
+	ch   chan linken.GroupData
+
+	// the latest state received, nil after (re)connected like the real clients
+	lastState *linken.GroupData
+	// the latest state received in the previous connections, used as the prevState when reconnecting
+	savedState *linken.GroupData
+
+	// partitions the node considers itself running
+	active []bool
+	outbox []linken.NotifyPartitionData
+}
+
+// Simulator ...
+type Simulator struct {
+	config Config
+	rand   *rand.Rand
+	clock  *linken.FakeClock
+	linken *linken.Linken
+	nodes  []*virtualNode
+
+	// number of nodes reconnecting after the server restarted, no new node joins until all of them reconnected
+	restarting int
+
+	step int
+	log  []string
+}
+
+// New ...
+func New(config Config) *Simulator {
+	s := &Simulator{
+		config: config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+		clock:  linken.NewFakeClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	s.linken = s.newLinken()
+
+	for i := 0; i < config.Nodes; i++ {
+		s.nodes = append(s.nodes, &virtualNode{
+			name:   fmt.Sprintf("node%02d", i+1),
+			active: make([]bool, config.Partitions),
+		})
+	}
+	return s
+}
+
+// Run runs the simulation with the config, returns *ViolationError if any invariant is violated
+func Run(config Config) error {
+	return New(config).Run()
+}
+
+func (s *Simulator) newLinken() *linken.Linken {
+	return linken.New(
+		linken.WithClock(s.clock),
+		linken.WithNodeExpiredDuration(s.config.ExpiredDuration),
+		linken.WithReplicaCount(s.config.Replicas),
+	)
+}
+
+func (s *Simulator) logf(format string, args ...interface{}) {
+	s.log = append(s.log, fmt.Sprintf("%d: ", s.step)+fmt.Sprintf(format, args...))
+}
+
+func (s *Simulator) violation(format string, args ...interface{}) error {
+	return &ViolationError{
+		Seed:    s.config.Seed,
+		Step:    s.step,
+		Message: fmt.Sprintf(format, args...),
+		Steps:   s.log,
+	}
+}
+
+// Run runs the random steps, then lets the group become quiet and checks it is balanced
+func (s *Simulator) Run() error {
+	for s.step = 1; s.step <= s.config.Steps; s.step++ {
+		s.randomStep()
+		if err := s.checkSafety(); err != nil {
+			return err
+		}
+	}
+	return s.runUntilQuiet()
+}
+
+type action struct {
+	weight int
+	run    func() bool
+}
+
+func (s *Simulator) randomStep() {
+	actions := []action{
+		{weight: 3, run: s.randomJoin},
+		{weight: 1, run: s.randomLeave},
+		{weight: 1, run: s.randomCrash},
+		{weight: 1, run: s.randomConnectionLost},
+		{weight: 8, run: s.randomDeliver},
+		{weight: 6, run: s.randomFlush},
+		{weight: 2, run: s.randomAdvance},
+		{weight: 1, run: s.randomRestart},
+	}
+	total := 0
+	for _, a := range actions {
+		total += a.weight
+	}
+
+	for {
+		n := s.rand.Intn(total)
+		for _, a := range actions {
+			if n < a.weight {
+				if a.run() {
+					return
+				}
+				break
+			}
+			n -= a.weight
+		}
+	}
+}
+
+func (s *Simulator) pickNode(status nodeStatus) *virtualNode {
+	var candidates []*virtualNode
+	for _, n := range s.nodes {
+		if n.status == status {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[s.rand.Intn(len(candidates))]
+}
+
+func (s *Simulator) randomJoin() bool {
+	if s.restarting > 0 {
+		return false
+	}
+	n := s.pickNode(nodeStatusDown)
+	if n == nil {
+		return false
+	}
+	s.logf("%s join", n.name)
+	s.connect(n, nil)
+	return true
+}
+
+func (s *Simulator) connect(n *virtualNode, prevState *linken.GroupData) {
+	sess, err := s.linken.JoinSession(groupName, n.name, s.config.Partitions, prevState)
+	if err != nil {
+		panic(err)
+	}
+	n.status = nodeStatusJoined
+	n.sess = sess
+	n.ch = make(chan linken.GroupData, 1)
+	n.lastState = nil
+	n.outbox = nil
+	s.watch(n, 0)
+}
+
+func (s *Simulator) watch(n *virtualNode, fromVersion linken.GroupVersion) {
+	s.linken.Watch(groupName, linken.WatchRequest{
+		FromVersion:  fromVersion,
+		ResponseChan: n.ch,
+	})
+}
+
+func (s *Simulator) closeConnection(n *virtualNode) {
+	s.linken.RemoveWatch(groupName, n.ch)
+	n.ch = nil
+	n.outbox = nil
+	if n.lastState != nil {
+		n.savedState = n.lastState
+	}
+}
+
+func (s *Simulator) stopAll(n *virtualNode) {
+	for i := range n.active {
+		n.active[i] = false
+	}
+	n.lastState = nil
+	n.savedState = nil
+}
+
+func (s *Simulator) randomLeave() bool {
+	n := s.pickNode(nodeStatusJoined)
+	if n == nil {
+		return false
+	}
+	s.logf("%s leave", n.name)
+	s.closeConnection(n)
+	s.stopAll(n)
+	s.linken.LeaveSession(groupName, n.name, n.sess.ID)
+	n.status = nodeStatusDown
+	return true
+}
+
+func (s *Simulator) randomCrash() bool {
+	n := s.pickNode(nodeStatusJoined)
+	if n == nil {
+		return false
+	}
+	s.logf("%s crash", n.name)
+	s.closeConnection(n)
+	s.stopAll(n)
+	s.linken.DisconnectSession(groupName, n.name, n.sess.ID)
+	n.status = nodeStatusDown
+	return true
+}
+
+func (s *Simulator) randomConnectionLost() bool {
+	n := s.pickNode(nodeStatusJoined)
+	if n == nil {
+		return false
+	}
+	s.logf("%s connection lost", n.name)
+	s.closeConnection(n)
+	s.linken.DisconnectSession(groupName, n.name, n.sess.ID)
+	s.scheduleReconnect(n, s.randomReconnectDelay(), false)
+	return true
+}
+
+func (s *Simulator) randomReconnectDelay() time.Duration {
+	return time.Duration(s.rand.Int63n(int64(s.config.ExpiredDuration)))
+}
+
+// scheduleReconnect makes the node reconnect after delay, must be less than the expired duration
+//
+//revive:disable-next-line:flag-parameter
+func (s *Simulator) scheduleReconnect(n *virtualNode, delay time.Duration, afterRestart bool) {
+	n.status = nodeStatusReconnecting
+	if afterRestart {
+		s.restarting++
+	}
+
+	s.clock.AfterFunc(delay, func() {
+		s.logf("%s reconnect", n.name)
+		if afterRestart {
+			s.restarting--
+		}
+		s.connect(n, n.savedState)
+	})
+}
+
+func (s *Simulator) randomDeliver() bool {
+	n := s.pickNode(nodeStatusJoined)
+	if n == nil {
+		return false
+	}
+	return s.deliver(n)
+}
+
+func (s *Simulator) deliver(n *virtualNode) bool {
+	var data linken.GroupData
+	select {
+	case data = <-n.ch:
+	default:
+		return false
+	}
+
+	s.logf("%s receive version %d %v", n.name, data.Version, data.Partitions)
+	s.handleGroupData(n, data)
+	s.watch(n, data.Version+1)
+	return true
+}
+
+// handleGroupData is the behavior of a correct client: a partition is started when it is Starting
+// or Running and owned by the node, and is stopped before notifying the server it is stopped.
+// A node also stops a partition as soon as it sees the partition owned by others
+func (s *Simulator) handleGroupData(n *virtualNode, data linken.GroupData) {
+	for i, p := range data.Partitions {
+		id := linken.PartitionID(i)
+
+		var prevModVersion linken.GroupVersion
+		if n.lastState != nil {
+			prevModVersion = n.lastState.Partitions[i].ModVersion
+		}
+
+		if p.Owner != n.name {
+			n.active[id] = false
+			continue
+		}
+		if p.Status == linken.PartitionStatusRunning {
+			n.active[id] = true
+		}
+		if p.ModVersion <= prevModVersion {
+			continue
+		}
+
+		switch p.Status {
+		case linken.PartitionStatusStarting:
+			n.active[id] = true
+			n.outbox = append(n.outbox, linken.NotifyPartitionData{
+				Action:      linken.NotifyActionTypeRunning,
+				Partition:   id,
+				LastVersion: p.ModVersion,
+			})
+		case linken.PartitionStatusStopping:
+			n.active[id] = false
+			n.outbox = append(n.outbox, linken.NotifyPartitionData{
+				Action:      linken.NotifyActionTypeStopped,
+				Partition:   id,
+				LastVersion: p.ModVersion,
+			})
+		}
+	}
+	n.lastState = &data
+}
+
+func (s *Simulator) randomFlush() bool {
+	n := s.pickNode(nodeStatusJoined)
+	if n == nil {
+		return false
+	}
+	return s.flush(n)
+}
+
+func (s *Simulator) flush(n *virtualNode) bool {
+	if len(n.outbox) == 0 {
+		return false
+	}
+	s.logf("%s notify %v", n.name, n.outbox)
+	s.linken.NotifySession(groupName, n.name, n.sess.ID, n.outbox)
+	n.outbox = nil
+	return true
+}
+
+func (s *Simulator) randomAdvance() bool {
+	d := time.Duration(s.rand.Int63n(int64(s.config.ExpiredDuration / 2)))
+	s.logf("advance %v", d)
+	s.clock.Advance(d)
+	return true
+}
+
+// randomRestart replaces the server with a new one. The joined nodes reconnect with their latest state,
+// the node with the latest state is the first to reconnect, the others reconnect before expired
+func (s *Simulator) randomRestart() bool {
+	if s.rand.Intn(10) != 0 || s.pickNode(nodeStatusReconnecting) != nil {
+		return false
+	}
+
+	s.logf("server restart")
+	s.linken = s.newLinken()
+
+	var latest *virtualNode
+	var restarted []*virtualNode
+	for _, n := range s.nodes {
+		if n.status != nodeStatusJoined {
+			continue
+		}
+		s.closeConnection(n)
+		restarted = append(restarted, n)
+		if latest == nil || stateVersion(n.savedState) > stateVersion(latest.savedState) {
+			latest = n
+		}
+	}
+	if latest == nil {
+		return true
+	}
+
+	first := s.randomReconnectDelay()
+	s.scheduleReconnect(latest, first, true)
+	for _, n := range restarted {
+		if n == latest {
+			continue
+		}
+		delay := first + time.Duration(s.rand.Int63n(int64(s.config.ExpiredDuration-first)))
+		s.scheduleReconnect(n, delay, true)
+	}
+	return true
+}
+
+func stateVersion(data *linken.GroupData) linken.GroupVersion {
+	if data == nil {
+		return 0
+	}
+	return data.Version
+}
+
+func (s *Simulator) checkSafety() error {
+	data := s.currentState()
+	if err := linken.Validate(data); err != nil {
+		return s.violation("invalid group data %+v: %v", data, err)
+	}
+
+	for id := 0; id < s.config.Partitions; id++ {
+		var owners []string
+		for _, n := range s.nodes {
+			if n.active[id] {
+				owners = append(owners, n.name)
+			}
+		}
+		if len(owners) > 1 {
+			return s.violation("partition %d is running on multiple nodes %v", id, owners)
+		}
+	}
+	return nil
+}
+
+// runUntilQuiet stops the random operations, delivers all messages and waits for the reconnections and expirations
+func (s *Simulator) runUntilQuiet() error {
+	s.logf("quiet")
+	for round := 0; round < 100; round++ {
+		progress := false
+		for _, n := range s.nodes {
+			if n.status != nodeStatusJoined {
+				continue
+			}
+			for s.deliver(n) || s.flush(n) {
+				progress = true
+				if err := s.checkSafety(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if progress {
+			continue
+		}
+		if s.clock.PendingTimers() == 0 {
+			return s.checkQuiet()
+		}
+
+		s.logf("advance %v", s.config.ExpiredDuration)
+		s.clock.Advance(s.config.ExpiredDuration)
+		if err := s.checkSafety(); err != nil {
+			return err
+		}
+	}
+	return s.violation("group is not quiet after 100 rounds")
+}
+
+// checkQuiet checks every partition is running on exactly one node, and the partitions are balanced
+func (s *Simulator) checkQuiet() error {
+	data := s.currentState()
+
+	var joined []*virtualNode
+	for _, n := range s.nodes {
+		if n.status == nodeStatusJoined {
+			joined = append(joined, n)
+		}
+	}
+	if len(joined) != len(data.Nodes) {
+		return s.violation("nodes of the group %v not matched the joined nodes", data.Nodes)
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+	for id, p := range data.Partitions {
+		if p.Status != linken.PartitionStatusRunning {
+			return s.violation("partition %d is not running after quiet: %+v", id, p)
+		}
+		for _, n := range joined {
+			if n.active[id] != (n.name == p.Owner) {
+				return s.violation("partition %d is owned by %s, but running on %s: %v", id, p.Owner, n.name, n.active[id])
+			}
+		}
+		counts[p.Owner]++
+	}
+
+	low := s.config.Partitions / len(joined)
+	high := (s.config.Partitions + len(joined) - 1) / len(joined)
+	for _, n := range joined {
+		if counts[n.name] < low || counts[n.name] > high {
+			return s.violation("partitions not balanced: %v", counts)
+		}
+	}
+	return nil
+}
+
+// currentState returns the current group data of the server, empty if the group does not exist
+func (s *Simulator) currentState() linken.GroupData {
+	ch := make(chan linken.GroupData, 1)
+	s.linken.Watch(groupName, linken.WatchRequest{ResponseChan: ch})
+
+	select {
+	case data := <-ch:
+		return data
+	default:
+		s.linken.RemoveWatch(groupName, ch)
+		return linken.GroupData{}
+	}
+}
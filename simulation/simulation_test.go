@@ -0,0 +1,35 @@
+package simulation
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var seedFlag = flag.Int64("seed", 0, "replay the simulation with a single seed")
+
+func TestSimulation(t *testing.T) {
+	if *seedFlag != 0 {
+		assert.Equal(t, nil, Run(DefaultConfig(*seedFlag)))
+		return
+	}
+
+	for seed := int64(1); seed <= 200; seed++ {
+		err := Run(DefaultConfig(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSimulation_With_Replicas(t *testing.T) {
+	for seed := int64(1); seed <= 100; seed++ {
+		config := DefaultConfig(seed)
+		config.Replicas = 2
+
+		err := Run(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
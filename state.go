@@ -33,10 +33,14 @@ type groupState struct {
 	factory groupTimerFactory
 	options linkenOptions
 
+	epoch      GroupEpoch
 	version    GroupVersion
 	nodes      map[string]nodeInfo
 	partitions []PartitionInfo
 	timers     map[string]groupTimer
+
+	// replicas[i] are the standby nodes of the i-th partition, see WithReplicaCount
+	replicas [][]string
 }
 
 // PartitionInfo ...
@@ -52,6 +56,7 @@ func newGroupStateOptions(
 ) *groupState {
 	nodes := map[string]nodeInfo{}
 	partitions := make([]PartitionInfo, count)
+	replicas := make([][]string, count)
 	version := GroupVersion(0)
 
 	if prev != nil {
@@ -64,6 +69,11 @@ func newGroupStateOptions(
 		for i := range partitions {
 			partitions[i] = prev.Partitions[i]
 		}
+		if len(prev.Replicas) == count {
+			for i := range replicas {
+				replicas[i] = append([]string(nil), prev.Replicas[i]...)
+			}
+		}
 	}
 
 	s := &groupState{
@@ -74,6 +84,8 @@ func newGroupStateOptions(
 		nodes:      nodes,
 		partitions: partitions,
 		timers:     map[string]groupTimer{},
+
+		replicas: replicas,
 	}
 
 	if prev != nil {
@@ -105,10 +117,17 @@ func (s *groupState) reallocateSinglePartition(id PartitionID, expectedName stri
 			NextOwner:  "",
 			ModVersion: s.version + 1,
 		}
+		s.replicas[id] = nil
 		return
 	}
 
 	if prev.Owner == expectedName {
+		if prev.Status == PartitionStatusStopping {
+			// can not cancel the stopping, but the partition must not move to the stale next owner,
+			// otherwise the partitions stay unbalanced until the next reallocation.
+			// without the next owner it is reallocated again after stopped
+			s.partitions[id].NextOwner = ""
+		}
 		return
 	}
 
@@ -129,14 +148,12 @@ func (s *groupState) reallocate() {
 	if len(s.nodes) == 0 {
 		return
 	}
+	s.reallocateFrom(s.currentAssigns())
+}
 
-	nodes := make([]string, 0, len(s.nodes))
-	for nodeName := range s.nodes {
-		nodes = append(nodes, nodeName)
-	}
-	sort.Strings(nodes)
-
-	current := map[string][]PartitionID{}
+// currentAssigns returns the partitions of each node, ordered by partition id
+func (s *groupState) currentAssigns() partitionAssigns {
+	current := partitionAssigns{}
 	for i, p := range s.partitions {
 		id := PartitionID(i)
 
@@ -154,6 +171,15 @@ func (s *groupState) reallocate() {
 			current[currentName] = append(current[currentName], id)
 		}
 	}
+	return current
+}
+
+func (s *groupState) reallocateFrom(current partitionAssigns) {
+	nodes := make([]string, 0, len(s.nodes))
+	for nodeName := range s.nodes {
+		nodes = append(nodes, nodeName)
+	}
+	sort.Strings(nodes)
 
 	expected := reallocatePartitions(len(s.partitions), nodes, current)
 
@@ -162,6 +188,8 @@ func (s *groupState) reallocate() {
 			s.reallocateSinglePartition(id, expectedName)
 		}
 	}
+
+	s.assignReplicas()
 }
 
 func (s *groupState) nodeJoin(name string) bool {
@@ -221,11 +249,13 @@ func (s *groupState) notifyStopped(id PartitionID, owner string, lastVersion Gro
 			Owner:      prev.NextOwner,
 			ModVersion: s.version + 1,
 		}
+		s.assignReplicas()
 	} else {
 		s.partitions[id] = PartitionInfo{
 			Status:     PartitionStatusInit,
 			ModVersion: s.version + 1,
 		}
+		s.replicas[id] = nil
 		s.reallocate()
 	}
 	return true
@@ -258,10 +288,7 @@ func (s *groupState) nodeLeave(name string) bool {
 		}
 
 		if prev.Owner == name {
-			s.partitions[i] = PartitionInfo{
-				Status:     PartitionStatusInit,
-				ModVersion: s.version + 1,
-			}
+			s.promoteReplica(PartitionID(i))
 		}
 	}
 
@@ -286,6 +313,73 @@ func (s *groupState) nodeExpired(name string) bool {
 	return s.nodeLeave(name)
 }
 
+// transitionTimeout moves a partition stuck in Starting or Stopping since modVersion.
+// A Starting partition is reassigned to the least loaded other node, or to the same owner if there is no other node,
+// then the partitions are reallocated, keeping the moved partition on its new owner if that node has too many.
+// A Stopping partition is moved as if its owner notified it stopped
+func (s *groupState) transitionTimeout(id PartitionID, modVersion GroupVersion) bool {
+	prev := s.partitions[id]
+	if prev.ModVersion != modVersion {
+		return false
+	}
+
+	switch prev.Status {
+	case PartitionStatusStarting:
+		owner := s.leastLoadedNodeExcept(prev.Owner)
+		s.partitions[id] = PartitionInfo{
+			Status:     PartitionStatusStarting,
+			Owner:      owner,
+			ModVersion: s.version + 1,
+		}
+
+		// otherwise the moved partition could be stopped and moved back to the stuck node
+		current := s.currentAssigns()
+		current[owner] = moveToFront(current[owner], id)
+		s.reallocateFrom(current)
+		return true
+	case PartitionStatusStopping:
+		return s.notifyStopped(id, prev.Owner, modVersion)
+	default:
+		return false
+	}
+}
+
+// leastLoadedNodeExcept returns the alive node having the least partitions, other than the excluded node.
+// Returns the excluded node if there is no such node
+func (s *groupState) leastLoadedNodeExcept(excluded string) string {
+	counts := map[string]int{}
+	for _, p := range s.partitions {
+		switch {
+		case p.Status == PartitionStatusStopping && p.NextOwner != "":
+			counts[p.NextOwner]++
+		case p.Status == PartitionStatusStarting || p.Status == PartitionStatusRunning:
+			counts[p.Owner]++
+		}
+	}
+
+	result := excluded
+	for name, info := range s.nodes {
+		if name == excluded || info.status != nodeStatusAlive {
+			continue
+		}
+		if result == excluded || counts[name] < counts[result] || (counts[name] == counts[result] && name < result) {
+			result = name
+		}
+	}
+	return result
+}
+
+func moveToFront(ids []PartitionID, id PartitionID) []PartitionID {
+	for i, e := range ids {
+		if e == id {
+			copy(ids[1:i+1], ids[:i])
+			ids[0] = id
+			break
+		}
+	}
+	return ids
+}
+
 func (s *groupState) toGroupData() GroupData {
 	nodes := make([]string, 0, len(s.nodes))
 	for n := range s.nodes {
@@ -300,7 +394,9 @@ func (s *groupState) toGroupData() GroupData {
 
 	return GroupData{
 		Version:    s.version,
+		Epoch:      s.epoch,
 		Nodes:      nodes,
 		Partitions: clone,
+		Replicas:   s.cloneReplicas(),
 	}
 }
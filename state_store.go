@@ -0,0 +1,86 @@
+package linken
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ClientStateStore persists the last group state received by a client,
+// the loaded state is sent as the previous state when the client joins
+type ClientStateStore interface {
+	// Load returns nil if there is no saved state
+	Load() (*GroupData, error)
+	Save(data GroupData) error
+	// Clear removes the saved state, called when the server rejected it
+	Clear() error
+}
+
+// FileStateStore is a ClientStateStore saving the state as a JSON file
+type FileStateStore struct {
+	path string
+}
+
+var _ ClientStateStore = &FileStateStore{}
+
+// NewFileStateStore ...
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+// Load ...
+func (s *FileStateStore) Load() (*GroupData, error) {
+	content, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data GroupData
+	err = json.Unmarshal(content, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Save writes to a temporary file then renames it, so a crash never leaves a partially written state
+func (s *FileStateStore) Save(data GroupData) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
+
+// Clear ...
+func (s *FileStateStore) Clear() error {
+	err := os.Remove(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
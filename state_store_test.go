@@ -0,0 +1,68 @@
+package linken
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStateStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	store := NewFileStateStore(path)
+
+	data, err := store.Load()
+	assert.Equal(t, nil, err)
+	assert.Nil(t, data)
+
+	state := GroupData{
+		Version: 5,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 4},
+		},
+	}
+	err = store.Save(state)
+	assert.Equal(t, nil, err)
+
+	state.Version = 6
+	err = store.Save(state)
+	assert.Equal(t, nil, err)
+
+	data, err = store.Load()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &state, data)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestFileStateStore_Load_Invalid_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	err := ioutil.WriteFile(path, []byte("{"), 0600)
+	assert.Equal(t, nil, err)
+
+	data, err := NewFileStateStore(path).Load()
+	assert.NotEqual(t, nil, err)
+	assert.Nil(t, data)
+}
+
+func TestFileStateStore_Clear(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewFileStateStore(path)
+
+	err := store.Clear()
+	assert.Equal(t, nil, err)
+
+	err = store.Save(GroupData{Version: 5})
+	assert.Equal(t, nil, err)
+
+	err = store.Clear()
+	assert.Equal(t, nil, err)
+
+	data, err := store.Load()
+	assert.Equal(t, nil, err)
+	assert.Nil(t, data)
+}
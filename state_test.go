@@ -496,6 +496,24 @@ func TestGroupState_Rebalance_When_Stopping_Next_Owner_Empty(t *testing.T) {
 	}, s.partitions)
 }
 
+func TestGroupState_Rebalance_Stopping_Back_To_Owner(t *testing.T) {
+	s := newGroupState(2)
+	s.nodeJoin("node01")
+	s.version++
+	s.nodeJoin("node02")
+	s.version++
+
+	s.partitions[0] = PartitionInfo{
+		Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 2,
+	}
+	s.reallocate()
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 2},
+		{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "", ModVersion: 2},
+	}, s.partitions)
+}
+
 func TestGroupState_NodeDisconnect_And_Expired(t *testing.T) {
 	factory := &groupTimerFactoryMock{}
 
@@ -733,3 +751,98 @@ func TestGroupState_With_Prev_State_Need_Reallocate(t *testing.T) {
 		"node02": {},
 	}, names)
 }
+
+func TestGroupState_TransitionTimeout_Starting(t *testing.T) {
+	s := newGroupState(3)
+	s.nodeJoin("node01")
+	s.version++
+
+	s.nodeJoin("node02")
+	s.version++
+
+	changed := s.transitionTimeout(0, 1)
+	assert.Equal(t, true, changed)
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 3},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "", ModVersion: 2},
+	}, s.partitions)
+}
+
+func TestGroupState_TransitionTimeout_Starting_Stay_Balanced(t *testing.T) {
+	s := newGroupState(2)
+	s.nodeJoin("node01")
+	s.version++
+
+	s.notifyRunning(0, "node01", 1)
+	s.version++
+
+	s.nodeJoin("node02")
+	s.version++
+
+	s.notifyStopped(1, "node01", 3)
+	s.version++
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+		{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 4},
+	}, s.partitions)
+
+	changed := s.transitionTimeout(1, 4)
+	assert.Equal(t, true, changed)
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 5},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 5},
+	}, s.partitions)
+	assert.Equal(t, partitionAssigns{
+		"node01": {1},
+		"node02": {0},
+	}, s.currentAssigns())
+}
+
+func TestGroupState_TransitionTimeout_Starting_Single_Node(t *testing.T) {
+	s := newGroupState(2)
+	s.nodeJoin("node01")
+	s.version++
+
+	changed := s.transitionTimeout(1, 1)
+	assert.Equal(t, true, changed)
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 2},
+	}, s.partitions)
+}
+
+func TestGroupState_TransitionTimeout_Stopping(t *testing.T) {
+	s := newGroupState(3)
+	s.nodeJoin("node01")
+	s.version++
+
+	s.nodeJoin("node02")
+	s.version++
+
+	changed := s.transitionTimeout(2, 2)
+	assert.Equal(t, true, changed)
+
+	assert.Equal(t, []PartitionInfo{
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		{Status: PartitionStatusStarting, Owner: "node02", ModVersion: 3},
+	}, s.partitions)
+}
+
+func TestGroupState_TransitionTimeout_Mod_Version_Changed(t *testing.T) {
+	s := newGroupState(3)
+	s.nodeJoin("node01")
+	s.version++
+
+	s.notifyRunning(0, "node01", 1)
+	s.version++
+
+	assert.Equal(t, false, s.transitionTimeout(0, 1))
+	assert.Equal(t, false, s.transitionTimeout(0, 2))
+	assert.Equal(t, PartitionInfo{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2}, s.partitions[0])
+}
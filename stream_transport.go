@@ -0,0 +1,202 @@
+package linken
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+)
+
+// Stream transport sends length-prefixed frames over a TCP or Unix domain socket connection.
+// Each frame contains a 1-byte frame type, a 4-byte big-endian payload length and the payload.
+// The payload of a close frame is a 2-byte big-endian close code followed by the reason
+
+const (
+	streamFrameMessage byte = 1
+	streamFrameClose   byte = 2
+
+	streamFrameHeaderSize = 5
+	streamMaxPayloadSize  = 64 << 20
+
+	// 2-byte close code and a reason of at most 123 bytes, the same limit as websocket
+	streamMaxClosePayloadSize = 125
+
+	streamHandshakeTimeout = 10 * time.Second
+)
+
+var errStreamFrameTooBig = errors.New("stream frame payload is too big")
+
+type streamConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMut  sync.Mutex
+	closeSent bool
+}
+
+var _ Conn = &streamConn{}
+
+func newStreamConn(conn net.Conn) *streamConn {
+	return &streamConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+func (c *streamConn) writeFrame(frameType byte, payload []byte) error {
+	frame := make([]byte, streamFrameHeaderSize+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint32(frame[1:streamFrameHeaderSize], uint32(len(payload)))
+	copy(frame[streamFrameHeaderSize:], payload)
+
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+
+	if frameType == streamFrameClose {
+		c.closeSent = true
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func (c *streamConn) isCloseSent() bool {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+	return c.closeSent
+}
+
+func (c *streamConn) ReadJSON(v interface{}) error {
+	var header [streamFrameHeaderSize]byte
+	_, err := io.ReadFull(c.reader, header[:])
+	if err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > streamMaxPayloadSize {
+		return errStreamFrameTooBig
+	}
+
+	if header[0] == streamFrameClose {
+		if size > streamMaxClosePayloadSize {
+			return errStreamFrameTooBig
+		}
+		payload := make([]byte, size)
+		_, err = io.ReadFull(c.reader, payload)
+		if err != nil {
+			return err
+		}
+
+		closeErr := &CloseError{Code: CloseNormal}
+		if len(payload) >= 2 {
+			closeErr.Code = int(binary.BigEndian.Uint16(payload))
+			closeErr.Text = string(payload[2:])
+		}
+		// reply the close frame like websocket does
+		if !c.isCloseSent() {
+			_ = c.WriteClose(closeErr.Code, "")
+		}
+		return closeErr
+	}
+
+	return c.decodePayload(size, v)
+}
+
+// decodePayload decodes the payload while reading it instead of allocating the size sent by the peer up front,
+// so the memory used is bounded by the bytes actually received
+func (c *streamConn) decodePayload(size uint32, v interface{}) error {
+	payload := &io.LimitedReader{R: c.reader, N: int64(size)}
+	decodeErr := json.NewDecoder(payload).Decode(v)
+
+	// skip the rest of the frame to keep reading at the frame boundary
+	_, err := io.Copy(ioutil.Discard, payload)
+	if err != nil {
+		return err
+	}
+	if payload.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return decodeErr
+}
+
+func (c *streamConn) WriteJSON(v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(streamFrameMessage, payload)
+}
+
+func (c *streamConn) WriteClose(code int, reason string) error {
+	if len(reason) > streamMaxClosePayloadSize-2 {
+		reason = reason[:streamMaxClosePayloadSize-2]
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	return c.writeFrame(streamFrameClose, payload)
+}
+
+func (c *streamConn) Close() error {
+	return c.conn.Close()
+}
+
+type streamTransport struct {
+	network   string
+	address   string
+	dialer    net.Dialer
+	tlsConfig *tls.Config
+}
+
+// StreamTransportOption ...
+type StreamTransportOption func(t *streamTransport)
+
+// WithStreamTLSConfig dials the server using TLS with the config (including client certificates),
+// for a WebsocketHandler.ServeStream listener created by tls.NewListener.
+// ServerName of the config must be set for Unix domain sockets
+func WithStreamTLSConfig(config *tls.Config) StreamTransportOption {
+	return func(t *streamTransport) {
+		t.tlsConfig = config
+	}
+}
+
+// NewStreamTransport creates a transport using length-prefixed frames,
+// network is "tcp" or "unix", used with WebsocketHandler.ServeStream on the server side
+func NewStreamTransport(network string, address string, options ...StreamTransportOption) ClientTransport {
+	t := &streamTransport{
+		network: network,
+		address: address,
+	}
+	for _, o := range options {
+		o(t)
+	}
+	return t
+}
+
+func (t *streamTransport) Dial(ctx context.Context) (Conn, error) {
+	if t.tlsConfig != nil {
+		tlsDialer := &tls.Dialer{
+			NetDialer: &t.dialer,
+			Config:    t.tlsConfig,
+		}
+		conn, err := tlsDialer.DialContext(ctx, t.network, t.address)
+		if err != nil {
+			return nil, err
+		}
+		return newStreamConn(conn), nil
+	}
+
+	conn, err := t.dialer.DialContext(ctx, t.network, t.address)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamConn(conn), nil
+}
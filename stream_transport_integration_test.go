@@ -0,0 +1,167 @@
+package linken
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func runStreamTransportTest(t *testing.T, network string, address string) {
+	ln, err := net.Listen(network, address)
+	assert.Equal(t, nil, err)
+
+	handler := NewWebsocketHandler()
+
+	var serverWg sync.WaitGroup
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		err := handler.ServeStream(ln)
+		assert.Equal(t, nil, err)
+	}()
+
+	var mut sync.Mutex
+	var listenedNodes []string
+	var updated []partitionUpdated
+
+	transport := NewStreamTransport(network, ln.Addr().String())
+
+	client := NewClient(transport, "group01", "node01", 2,
+		WithClientNodeListener(func(nodes []string) {
+			mut.Lock()
+			listenedNodes = nodes
+			mut.Unlock()
+		}),
+		WithClientPartitionListener(func(p PartitionID, owner string) {
+			mut.Lock()
+			updated = append(updated, partitionUpdated{id: p, owner: owner})
+			mut.Unlock()
+		}),
+	)
+	anotherClient := NewClient(transport, "group01", "node02", 2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Run()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		anotherClient.Run()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []string{"node01", "node02"}, listenedNodes)
+	assert.Equal(t, []partitionUpdated{
+		{id: 0, owner: "node01"},
+		{id: 1, owner: "node01"},
+		{id: 1, owner: ""},
+		{id: 1, owner: "node02"},
+	}, updated)
+	mut.Unlock()
+
+	anotherClient.Shutdown()
+	time.Sleep(50 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []string{"node01"}, listenedNodes)
+	mut.Unlock()
+
+	client.Shutdown()
+	wg.Wait()
+
+	handler.Shutdown()
+	serverWg.Wait()
+
+	handler.linken.mut.RLock()
+	assert.Equal(t, 0, len(handler.linken.groups))
+	handler.linken.mut.RUnlock()
+}
+
+func TestStreamTransport_TCP(t *testing.T) {
+	runStreamTransportTest(t, "tcp", "127.0.0.1:0")
+}
+
+func TestStreamTransport_Unix(t *testing.T) {
+	runStreamTransportTest(t, "unix", filepath.Join(t.TempDir(), "linken.sock"))
+}
+
+func TestStreamTransport_Mutual_TLS(t *testing.T) {
+	ca := newTestCertAuthority()
+
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+	ln := tls.NewListener(tcpLn, &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(2, pkix.Name{CommonName: "server"}, x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+
+	handler := NewWebsocketHandler(WithNodeIdentity(CertificateNodeIdentity))
+
+	var serverWg sync.WaitGroup
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		err := handler.ServeStream(ln)
+		assert.Equal(t, nil, err)
+	}()
+
+	clientCert := ca.issue(3, pkix.Name{
+		CommonName:         "node01",
+		OrganizationalUnit: []string{"group01"},
+	}, x509.ExtKeyUsageClientAuth)
+
+	transport := NewStreamTransport("tcp", tcpLn.Addr().String(), WithStreamTLSConfig(&tls.Config{
+		RootCAs:      ca.pool,
+		Certificates: []tls.Certificate{clientCert},
+	}))
+
+	var mut sync.Mutex
+	var listenedNodes []string
+
+	impersonate := NewClient(transport, "group01", "node02", 2)
+	client := NewClient(transport, "group01", "node01", 2,
+		WithClientNodeListener(func(nodes []string) {
+			mut.Lock()
+			listenedNodes = nodes
+			mut.Unlock()
+		}),
+	)
+
+	var wg sync.WaitGroup
+	for _, c := range []*WebsocketClient{impersonate, client} {
+		wg.Add(1)
+		go func(c *WebsocketClient) {
+			defer wg.Done()
+			c.Run()
+		}(c)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	mut.Lock()
+	assert.Equal(t, []string{"node01"}, listenedNodes)
+	mut.Unlock()
+	assert.Equal(t, false, impersonate.Snapshot().Connected)
+
+	impersonate.Shutdown()
+	client.Shutdown()
+	wg.Wait()
+
+	handler.Shutdown()
+	serverWg.Wait()
+}
@@ -0,0 +1,152 @@
+package linken
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+)
+
+func TestStreamConn_Read_Write_JSON(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn1 := newStreamConn(c1)
+	conn2 := newStreamConn(c2)
+	defer func() { _ = conn1.Close() }()
+	defer func() { _ = conn2.Close() }()
+
+	go func() {
+		_ = conn1.WriteJSON(ServerCommand{
+			Type: ServerCommandTypeNotify,
+			Notify: []NotifyPartitionData{
+				{Action: NotifyActionTypeRunning, Partition: 2, LastVersion: 5},
+			},
+		})
+	}()
+
+	var cmd ServerCommand
+	err := conn2.ReadJSON(&cmd)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ServerCommand{
+		Type: ServerCommandTypeNotify,
+		Notify: []NotifyPartitionData{
+			{Action: NotifyActionTypeRunning, Partition: 2, LastVersion: 5},
+		},
+	}, cmd)
+}
+
+func TestStreamConn_Close_Replied(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn1 := newStreamConn(c1)
+	conn2 := newStreamConn(c2)
+	defer func() { _ = conn1.Close() }()
+	defer func() { _ = conn2.Close() }()
+
+	var replyErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_ = conn1.WriteClose(ClosePolicyViolation, "some reason")
+
+		var cmd ServerCommand
+		replyErr = conn1.ReadJSON(&cmd)
+	}()
+
+	var cmd ServerCommand
+	err := conn2.ReadJSON(&cmd)
+	assert.Equal(t, &CloseError{Code: ClosePolicyViolation, Text: "some reason"}, err)
+
+	<-done
+	assert.Equal(t, &CloseError{Code: ClosePolicyViolation}, replyErr)
+}
+
+func TestStreamConn_Frame_Too_Big(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn2 := newStreamConn(c2)
+	defer func() { _ = c1.Close() }()
+	defer func() { _ = conn2.Close() }()
+
+	go func() {
+		header := make([]byte, streamFrameHeaderSize)
+		header[0] = streamFrameMessage
+		binary.BigEndian.PutUint32(header[1:], streamMaxPayloadSize+1)
+		_, _ = c1.Write(header)
+	}()
+
+	var cmd ServerCommand
+	err := conn2.ReadJSON(&cmd)
+	assert.Equal(t, errStreamFrameTooBig, err)
+}
+
+func writeStreamFrame(conn net.Conn, frameType byte, size uint32, payload []byte) {
+	header := make([]byte, streamFrameHeaderSize)
+	header[0] = frameType
+	binary.BigEndian.PutUint32(header[1:], size)
+	_, _ = conn.Write(header)
+	_, _ = conn.Write(payload)
+}
+
+func TestStreamConn_Big_Size_Not_Allocated_Before_Received(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn2 := newStreamConn(c2)
+	defer func() { _ = conn2.Close() }()
+
+	go func() {
+		writeStreamFrame(c1, streamFrameMessage, 32<<20, []byte(`{"type":"join"}`))
+		_ = c1.Close()
+	}()
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var cmd ServerCommand
+	err := conn2.ReadJSON(&cmd)
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(4<<20))
+}
+
+func TestStreamConn_Read_At_Frame_Boundary(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn2 := newStreamConn(c2)
+	defer func() { _ = c1.Close() }()
+	defer func() { _ = conn2.Close() }()
+
+	go func() {
+		payload := []byte(`{"type":"join"}   `)
+		writeStreamFrame(c1, streamFrameMessage, uint32(len(payload)), payload)
+
+		payload = []byte(`{"type":"notify"}`)
+		writeStreamFrame(c1, streamFrameMessage, uint32(len(payload)), payload)
+	}()
+
+	var cmd ServerCommand
+	err := conn2.ReadJSON(&cmd)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ServerCommandTypeJoin, cmd.Type)
+
+	cmd = ServerCommand{}
+	err = conn2.ReadJSON(&cmd)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ServerCommandTypeNotify, cmd.Type)
+}
+
+func TestStreamConn_Close_Frame_Too_Big(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn2 := newStreamConn(c2)
+	defer func() { _ = c1.Close() }()
+	defer func() { _ = conn2.Close() }()
+
+	go func() {
+		writeStreamFrame(c1, streamFrameClose, streamMaxClosePayloadSize+1, nil)
+	}()
+
+	var cmd ServerCommand
+	err := conn2.ReadJSON(&cmd)
+	assert.Equal(t, errStreamFrameTooBig, err)
+}
@@ -0,0 +1,108 @@
+package linken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"time"
+)
+
+// Close codes sent with Conn.WriteClose, the same values as the websocket close codes
+const (
+	CloseNormal          = 1000
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseInternalError   = 1011
+)
+
+// Conn is a connection carrying JSON messages (ServerCommand, ServerResponse) between nodes and the server.
+// ReadJSON can be called concurrently with WriteJSON, WriteClose and Close can be called concurrently with all methods
+type Conn interface {
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+
+	// WriteClose sends a close message, ReadJSON of the peer returns a *CloseError with the same code and reason
+	WriteClose(code int, reason string) error
+	Close() error
+}
+
+// CloseError is returned from Conn.ReadJSON after receiving a close message
+type CloseError struct {
+	Code int
+	Text string
+}
+
+var _ error = &CloseError{}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("connection closed with code %d: %s", e.Code, e.Text)
+}
+
+// ClientTransport creates connections from nodes to the server
+type ClientTransport interface {
+	Dial(ctx context.Context) (Conn, error)
+}
+
+func errorIsCloseNormal(err error) bool {
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) {
+		return false
+	}
+	return closeErr.Code == CloseNormal
+}
+
+//=================================
+// Websocket Transport
+//=================================
+
+const closeWriteTimeout = 5 * time.Second
+
+type websocketConn struct {
+	conn *websocket.Conn
+}
+
+var _ Conn = websocketConn{}
+
+func (c websocketConn) ReadJSON(v interface{}) error {
+	err := c.conn.ReadJSON(v)
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return &CloseError{Code: closeErr.Code, Text: closeErr.Text}
+	}
+	return err
+}
+
+func (c websocketConn) WriteJSON(v interface{}) error {
+	return c.conn.WriteJSON(v)
+}
+
+func (c websocketConn) WriteClose(code int, reason string) error {
+	return c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason), time.Now().Add(closeWriteTimeout))
+}
+
+func (c websocketConn) Close() error {
+	return c.conn.Close()
+}
+
+type websocketTransport struct {
+	url    string
+	dialer *websocket.Dialer
+}
+
+// NewWebsocketTransport ...
+func NewWebsocketTransport(url string, dialer *websocket.Dialer) ClientTransport {
+	return websocketTransport{
+		url:    url,
+		dialer: dialer,
+	}
+}
+
+func (t websocketTransport) Dial(ctx context.Context) (Conn, error) {
+	conn, _, err := t.dialer.DialContext(ctx, t.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return websocketConn{conn: conn}, nil
+}
@@ -0,0 +1,95 @@
+package linken
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks the structural invariants of the group data:
+// node names are unique and not empty, versions of partitions are not greater than the group version,
+// owners of the partitions are in the nodes, the owners match the status of the partitions,
+// and the replicas are unique nodes other than the owners
+func Validate(data GroupData) error {
+	nodes := map[string]struct{}{}
+	for _, n := range data.Nodes {
+		if len(n) == 0 {
+			return errors.New("'nodes' field must not contain empty names")
+		}
+		if _, existed := nodes[n]; existed {
+			return fmt.Errorf("'nodes' field contains duplicated node '%s'", n)
+		}
+		nodes[n] = struct{}{}
+	}
+
+	if len(data.Replicas) > 0 && len(data.Replicas) != len(data.Partitions) {
+		return errors.New("'replicas' field must have the same length as 'partitions'")
+	}
+
+	for i, p := range data.Partitions {
+		if p.Status < 0 || p.Status > PartitionStatusStopping {
+			return errors.New("partitions 'status' field is invalid")
+		}
+		if p.ModVersion > data.Version {
+			return errors.New("partitions 'modVersion' field is too big")
+		}
+		if err := validatePartitionOwners(p, nodes); err != nil {
+			return err
+		}
+		if err := validatePartitionReplicas(p, replicasOf(data, PartitionID(i)), nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePartitionOwners(p PartitionInfo, nodes map[string]struct{}) error {
+	if p.Status == PartitionStatusInit {
+		if p.Owner != "" || p.NextOwner != "" {
+			return errors.New("partitions with init status must not have 'owner' or 'nextOwner'")
+		}
+		return nil
+	}
+
+	if _, existed := nodes[p.Owner]; !existed {
+		return fmt.Errorf("partitions 'owner' field '%s' is not in nodes", p.Owner)
+	}
+
+	if p.Status != PartitionStatusStopping {
+		if p.NextOwner != "" {
+			return errors.New("only partitions with stopping status can have 'nextOwner'")
+		}
+		return nil
+	}
+
+	if p.NextOwner == "" {
+		return nil
+	}
+	if p.NextOwner == p.Owner {
+		return errors.New("partitions 'nextOwner' field must be different from 'owner'")
+	}
+	if _, existed := nodes[p.NextOwner]; !existed {
+		return fmt.Errorf("partitions 'nextOwner' field '%s' is not in nodes", p.NextOwner)
+	}
+	return nil
+}
+
+func validatePartitionReplicas(p PartitionInfo, replicas []string, nodes map[string]struct{}) error {
+	if p.Status == PartitionStatusInit && len(replicas) > 0 {
+		return errors.New("partitions with init status must not have 'replicas'")
+	}
+
+	seen := map[string]struct{}{}
+	for _, r := range replicas {
+		if _, existed := nodes[r]; !existed {
+			return fmt.Errorf("partitions 'replicas' field '%s' is not in nodes", r)
+		}
+		if r == p.Owner || r == p.NextOwner {
+			return errors.New("partitions 'replicas' field must not contain 'owner' or 'nextOwner'")
+		}
+		if _, existed := seen[r]; existed {
+			return fmt.Errorf("partitions 'replicas' field contains duplicated node '%s'", r)
+		}
+		seen[r] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package linken
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	table := []struct {
+		name string
+		data GroupData
+		err  error
+	}{
+		{
+			name: "empty",
+			data: GroupData{},
+			err:  nil,
+		},
+		{
+			name: "ok",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01", "node02", "node03"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusInit, ModVersion: 3},
+					{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 4},
+					{Status: PartitionStatusRunning, Owner: "node02", ModVersion: 5},
+					{Status: PartitionStatusStopping, Owner: "node02", NextOwner: "node03", ModVersion: 10},
+					{Status: PartitionStatusStopping, Owner: "node03", ModVersion: 10},
+				},
+			},
+			err: nil,
+		},
+		{
+			name: "empty-node-name",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01", ""},
+			},
+			err: errors.New("'nodes' field must not contain empty names"),
+		},
+		{
+			name: "duplicated-node",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01", "node02", "node01"},
+			},
+			err: errors.New("'nodes' field contains duplicated node 'node01'"),
+		},
+		{
+			name: "status-invalid",
+			data: GroupData{
+				Version:    10,
+				Nodes:      []string{"node01"},
+				Partitions: []PartitionInfo{{Status: 4, Owner: "node01", ModVersion: 10}},
+			},
+			err: errors.New("partitions 'status' field is invalid"),
+		},
+		{
+			name: "mod-version-too-big",
+			data: GroupData{
+				Version:    10,
+				Nodes:      []string{"node01"},
+				Partitions: []PartitionInfo{{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 11}},
+			},
+			err: errors.New("partitions 'modVersion' field is too big"),
+		},
+		{
+			name: "init-with-owner",
+			data: GroupData{
+				Version:    10,
+				Nodes:      []string{"node01"},
+				Partitions: []PartitionInfo{{Status: PartitionStatusInit, Owner: "node01", ModVersion: 10}},
+			},
+			err: errors.New("partitions with init status must not have 'owner' or 'nextOwner'"),
+		},
+		{
+			name: "owner-not-in-nodes",
+			data: GroupData{
+				Version:    10,
+				Nodes:      []string{"node01"},
+				Partitions: []PartitionInfo{{Status: PartitionStatusRunning, Owner: "node02", ModVersion: 10}},
+			},
+			err: errors.New("partitions 'owner' field 'node02' is not in nodes"),
+		},
+		{
+			name: "running-with-next-owner",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01", "node02"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusRunning, Owner: "node01", NextOwner: "node02", ModVersion: 10},
+				},
+			},
+			err: errors.New("only partitions with stopping status can have 'nextOwner'"),
+		},
+		{
+			name: "next-owner-same-as-owner",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node01", ModVersion: 10},
+				},
+			},
+			err: errors.New("partitions 'nextOwner' field must be different from 'owner'"),
+		},
+		{
+			name: "next-owner-not-in-nodes",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 10},
+				},
+			},
+			err: errors.New("partitions 'nextOwner' field 'node02' is not in nodes"),
+		},
+		{
+			name: "init-with-replicas",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusInit, ModVersion: 10},
+				},
+				Replicas: [][]string{{"node01"}},
+			},
+			err: errors.New("partitions with init status must not have 'replicas'"),
+		},
+		{
+			name: "replica-not-in-nodes",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 10},
+				},
+				Replicas: [][]string{{"node02"}},
+			},
+			err: errors.New("partitions 'replicas' field 'node02' is not in nodes"),
+		},
+		{
+			name: "replica-same-as-next-owner",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01", "node02"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 10},
+				},
+				Replicas: [][]string{{"node02"}},
+			},
+			err: errors.New("partitions 'replicas' field must not contain 'owner' or 'nextOwner'"),
+		},
+		{
+			name: "duplicated-replicas",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01", "node02"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 10},
+				},
+				Replicas: [][]string{{"node02", "node02"}},
+			},
+			err: errors.New("partitions 'replicas' field contains duplicated node 'node02'"),
+		},
+		{
+			name: "replicas-length-mismatch",
+			data: GroupData{
+				Version: 10,
+				Nodes:   []string{"node01", "node02"},
+				Partitions: []PartitionInfo{
+					{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 10},
+					{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 10},
+				},
+				Replicas: [][]string{{"node02"}},
+			},
+			err: errors.New("'replicas' field must have the same length as 'partitions'"),
+		},
+	}
+	for _, e := range table {
+		t.Run(e.name, func(t *testing.T) {
+			assert.Equal(t, e.err, Validate(e.data))
+		})
+	}
+}
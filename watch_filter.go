@@ -0,0 +1,132 @@
+package linken
+
+// WatchFilter limits a watch to a subset of the group state, the zero value matches every change.
+// A change matches if it matches any of the non-empty fields
+type WatchFilter struct {
+	// changes of these partitions
+	Partitions []PartitionID `json:"partitions,omitempty"`
+	// changes of partitions owned by, moving from / to, or replicated on this node
+	Owner string `json:"owner,omitempty"`
+	// changes of the node list
+	Membership bool `json:"membership,omitempty"`
+}
+
+func (f WatchFilter) isEmpty() bool {
+	return len(f.Partitions) == 0 && len(f.Owner) == 0 && !f.Membership
+}
+
+type filteredWatch struct {
+	fromVersion GroupVersion
+	filter      WatchFilter
+	ch          chan<- GroupData
+}
+
+// groupChanges stores the versions at which parts of the group state changed for the last time
+type groupChanges struct {
+	published  GroupData
+	nodes      GroupVersion
+	partitions []GroupVersion
+	owners     map[string]GroupVersion
+}
+
+func newGroupChanges() groupChanges {
+	return groupChanges{
+		owners: map[string]GroupVersion{},
+	}
+}
+
+func nodeListEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// record compares the current state with the previously recorded one
+func (c *groupChanges) record(current GroupData) {
+	version := current.Version
+	prev := c.published
+
+	if !nodeListEqual(prev.Nodes, current.Nodes) {
+		c.nodes = version
+	}
+
+	if len(c.partitions) != len(current.Partitions) {
+		c.partitions = make([]GroupVersion, len(current.Partitions))
+	}
+
+	for i, p := range current.Partitions {
+		var old PartitionInfo
+		if i < len(prev.Partitions) {
+			old = prev.Partitions[i]
+		}
+		oldReplicas := replicasOf(prev, PartitionID(i))
+		replicas := replicasOf(current, PartitionID(i))
+		if old == p && replicasEqual(oldReplicas, replicas) {
+			continue
+		}
+
+		c.partitions[i] = version
+		names := []string{old.Owner, old.NextOwner, p.Owner, p.NextOwner}
+		names = append(names, oldReplicas...)
+		names = append(names, replicas...)
+		for _, name := range names {
+			if len(name) > 0 {
+				c.owners[name] = version
+			}
+		}
+	}
+
+	c.pruneOwners(current)
+	c.published = current
+}
+
+// pruneOwners removes the nodes that are not in the group and not involved in the latest change
+func (c *groupChanges) pruneOwners(current GroupData) {
+	alive := map[string]struct{}{}
+	for _, n := range current.Nodes {
+		alive[n] = struct{}{}
+	}
+	for name, version := range c.owners {
+		if _, ok := alive[name]; ok || version == current.Version {
+			continue
+		}
+		delete(c.owners, name)
+	}
+}
+
+func (c *groupChanges) matched(filter WatchFilter, fromVersion GroupVersion) bool {
+	if filter.Membership && c.nodes >= fromVersion {
+		return true
+	}
+	for _, id := range filter.Partitions {
+		if int(id) < len(c.partitions) && c.partitions[id] >= fromVersion {
+			return true
+		}
+	}
+	if len(filter.Owner) > 0 {
+		version, ok := c.owners[filter.Owner]
+		if ok && version >= fromVersion {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFilteredWatch(watches []filteredWatch, ch chan<- GroupData) []filteredWatch {
+	result := watches[:0]
+	for _, w := range watches {
+		if w.ch != ch {
+			result = append(result, w)
+		}
+	}
+	for i := len(result); i < len(watches); i++ {
+		watches[i] = filteredWatch{}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package linken
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGroupChanges_Record(t *testing.T) {
+	c := newGroupChanges()
+
+	c.record(GroupData{
+		Version: 1,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		},
+	})
+	assert.Equal(t, GroupVersion(1), c.nodes)
+	assert.Equal(t, []GroupVersion{1, 1}, c.partitions)
+	assert.Equal(t, map[string]GroupVersion{"node01": 1}, c.owners)
+
+	c.record(GroupData{
+		Version: 2,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 1},
+		},
+	})
+	assert.Equal(t, GroupVersion(1), c.nodes)
+	assert.Equal(t, []GroupVersion{2, 1}, c.partitions)
+
+	c.record(GroupData{
+		Version: 3,
+		Nodes:   []string{"node01", "node02"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStopping, Owner: "node01", NextOwner: "node02", ModVersion: 3},
+		},
+	})
+	assert.Equal(t, GroupVersion(3), c.nodes)
+	assert.Equal(t, []GroupVersion{2, 3}, c.partitions)
+	assert.Equal(t, map[string]GroupVersion{"node01": 3, "node02": 3}, c.owners)
+
+	c.record(GroupData{
+		Version: 4,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStopping, Owner: "node01", ModVersion: 3},
+		},
+	})
+	assert.Equal(t, map[string]GroupVersion{"node01": 4, "node02": 4}, c.owners)
+
+	c.record(GroupData{
+		Version: 5,
+		Nodes:   []string{"node01"},
+		Partitions: []PartitionInfo{
+			{Status: PartitionStatusRunning, Owner: "node01", ModVersion: 2},
+			{Status: PartitionStatusStarting, Owner: "node01", ModVersion: 5},
+		},
+	})
+	assert.Equal(t, map[string]GroupVersion{"node01": 5}, c.owners)
+}
+
+func TestGroupChanges_Matched(t *testing.T) {
+	c := groupChanges{
+		nodes:      3,
+		partitions: []GroupVersion{2, 5, 4},
+		owners:     map[string]GroupVersion{"node01": 5, "node02": 3},
+	}
+
+	table := []struct {
+		name        string
+		filter      WatchFilter
+		fromVersion GroupVersion
+		matched     bool
+	}{
+		{name: "membership", filter: WatchFilter{Membership: true}, fromVersion: 3, matched: true},
+		{name: "membership-not-changed", filter: WatchFilter{Membership: true}, fromVersion: 4, matched: false},
+		{name: "partitions", filter: WatchFilter{Partitions: []PartitionID{0, 2}}, fromVersion: 4, matched: true},
+		{name: "partitions-not-changed", filter: WatchFilter{Partitions: []PartitionID{0, 2}}, fromVersion: 5},
+		{name: "partition-out-of-range", filter: WatchFilter{Partitions: []PartitionID{3}}, fromVersion: 1},
+		{name: "owner", filter: WatchFilter{Owner: "node01"}, fromVersion: 5, matched: true},
+		{name: "owner-not-changed", filter: WatchFilter{Owner: "node02"}, fromVersion: 4},
+		{name: "owner-unknown", filter: WatchFilter{Owner: "node03"}, fromVersion: 1},
+		{
+			name:        "any-field",
+			filter:      WatchFilter{Owner: "node02", Partitions: []PartitionID{1}},
+			fromVersion: 5,
+			matched:     true,
+		},
+	}
+
+	for _, e := range table {
+		t.Run(e.name, func(t *testing.T) {
+			assert.Equal(t, e.matched, c.matched(e.filter, e.fromVersion))
+		})
+	}
+}